@@ -6,28 +6,138 @@ CREATE TABLE IF NOT EXISTS users (
     profile_picture_url TEXT,
     -- TODO: change default value back to false; set to true to avoid email verification
     is_verified BOOLEAN NOT NULL DEFAULT True,
-    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ, -- When the user's last connection went away
+    hide_presence BOOLEAN NOT NULL DEFAULT FALSE, -- Privacy: friends see the user as offline
+    status_text TEXT NOT NULL DEFAULT '',
+    status_emoji TEXT NOT NULL DEFAULT '',
+    status_expires_at TIMESTAMPTZ, -- Custom status is ignored after this
+    dnd_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    dnd_schedule JSONB -- {"start": "22:00", "end": "07:00", "time_zone": "Europe/Berlin"}
 );
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS hide_presence BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_text TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_emoji TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_expires_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS dnd_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS dnd_schedule JSONB;
 
 CREATE TABLE IF NOT EXISTS conversations (
     id UUID PRIMARY KEY,
     type VARCHAR(20) NOT NULL, -- 'one-on-one' or 'group'
-    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    message_ttl_seconds INT -- Disappearing messages: messages sent while set are deleted this long after being sent; NULL keeps them
 );
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS message_ttl_seconds INT;
 
 CREATE TABLE IF NOT EXISTS conversation_participants (
     conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
     user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
     last_read_timestamp TIMESTAMPTZ DEFAULT NOW(),
+    last_delivered_timestamp TIMESTAMPTZ DEFAULT NOW(), -- Newest message a device of the user has acknowledged
     PRIMARY KEY (conversation_id, user_id)
 );
+ALTER TABLE conversation_participants ADD COLUMN IF NOT EXISTS last_delivered_timestamp TIMESTAMPTZ DEFAULT NOW();
 
 CREATE TABLE IF NOT EXISTS messages (
     id UUID PRIMARY KEY,
     conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
     sender_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
     content TEXT NOT NULL CHECK (length(content) <= 500),
-    server_timestamp TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    server_timestamp TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    edited_at TIMESTAMPTZ, -- Set when the sender last edited the message
+    deleted_at TIMESTAMPTZ, -- Set when deleted for everyone; the row stays as a tombstone with empty content
+    reply_to_id UUID REFERENCES messages(id) ON DELETE SET NULL, -- Message quoted inline by this one
+    thread_root_id UUID REFERENCES messages(id) ON DELETE SET NULL, -- Set on replies posted in a sub-thread
+    system BOOLEAN NOT NULL DEFAULT FALSE, -- Generated by the server, e.g. when a setting changes; the sender is who changed it
+    expires_at TIMESTAMPTZ, -- When the message disappears, fixed from the conversation's TTL when it was sent
+    -- Where a forwarded message was first sent; no foreign keys so provenance outlives the original
+    forwarded_from_message_id UUID,
+    forwarded_from_conversation_id UUID,
+    forwarded_from_sender_id UUID,
+    -- 'simple' (no stemming) because conversations mix languages
+    search_vector TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED
+);
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS edited_at TIMESTAMPTZ;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS reply_to_id UUID REFERENCES messages(id) ON DELETE SET NULL;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS thread_root_id UUID REFERENCES messages(id) ON DELETE SET NULL;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS system BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS forwarded_from_message_id UUID;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS forwarded_from_conversation_id UUID;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS forwarded_from_sender_id UUID;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED;
+
+-- Prior contents of edited messages, oldest first
+CREATE TABLE IF NOT EXISTS message_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL -- When this content was written
+);
+
+-- Files uploaded into a conversation; message_id stays NULL until a message is sent with them
+CREATE TABLE IF NOT EXISTS attachments (
+    id UUID PRIMARY KEY,
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    uploader_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    message_id UUID REFERENCES messages(id) ON DELETE CASCADE,
+    file_name TEXT NOT NULL,
+    mime_type VARCHAR(255) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    width INT, -- Images only
+    height INT, -- Images only
+    checksum CHAR(64) NOT NULL, -- Hex SHA-256 of the content
+    storage_key TEXT NOT NULL, -- Where the blob lives; shared by copies of the same file
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- One row per user per emoji on a message
+CREATE TABLE IF NOT EXISTS message_reactions (
+    message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    emoji VARCHAR(32) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (message_id, user_id, emoji)
+);
+
+-- Messages a participant deleted for themselves only
+CREATE TABLE IF NOT EXISTS message_hidden (
+    message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    hidden_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, message_id)
+);
+
+-- Messages pinned to the top of their conversation
+CREATE TABLE IF NOT EXISTS pinned_messages (
+    message_id UUID PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE,
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    pinned_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    pinned_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Messages users saved for later, with an optional private note
+CREATE TABLE IF NOT EXISTS bookmarks (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, message_id)
+);
+
+-- Messages waiting to be sent at a later time; rows are deleted once sent
+CREATE TABLE IF NOT EXISTS scheduled_messages (
+    id UUID PRIMARY KEY,
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    sender_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    send_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    claimed_until TIMESTAMPTZ -- Set while a node is sending it
 );
 
 CREATE TABLE IF NOT EXISTS friendships (
@@ -63,13 +173,61 @@ CREATE TABLE IF NOT EXISTS games (
 CREATE TABLE IF NOT EXISTS events (
     id UUID PRIMARY KEY,
     user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- User to whom this event is relevant
+    seq BIGINT, -- Strictly increasing per user; the cursor for long polling and replay
     event_type VARCHAR(50) NOT NULL, -- e.g., 'new_message', 'friend_request', 'game_invite', 'game_update'
     payload JSONB NOT NULL, -- The actual data of the event
     server_timestamp TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
+-- Last sequence number handed out per user. Bumping this row locks it until the
+-- event insert commits, so a user's events always commit in sequence order.
+CREATE TABLE IF NOT EXISTS user_event_sequences (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    last_seq BIGINT NOT NULL
+);
+
+-- Upgrade databases created before events carried a sequence number
+ALTER TABLE events ADD COLUMN IF NOT EXISTS seq BIGINT;
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM events WHERE seq IS NULL) THEN
+        UPDATE events e SET seq = n.rn
+        FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY server_timestamp, id) AS rn FROM events) n
+        WHERE e.id = n.id;
+        INSERT INTO user_event_sequences (user_id, last_seq)
+        SELECT user_id, MAX(seq) FROM events GROUP BY user_id
+        ON CONFLICT (user_id) DO UPDATE SET last_seq = EXCLUDED.last_seq;
+    END IF;
+END $$;
+ALTER TABLE events ALTER COLUMN seq SET NOT NULL;
+
+-- Wake long-poll waiters on every node as soon as an event for their user commits
+CREATE OR REPLACE FUNCTION notify_user_event() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('user_events', NEW.user_id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS events_notify_insert ON events;
+CREATE TRIGGER events_notify_insert AFTER INSERT ON events
+    FOR EACH ROW EXECUTE FUNCTION notify_user_event();
+
 
-CREATE INDEX IF NOT EXISTS idx_messages_conversation_timestamp ON messages (conversation_id, server_timestamp DESC);
+-- History is paged by (server_timestamp, id) cursors; these superseded the timestamp-only indexes
+DROP INDEX IF EXISTS idx_messages_conversation_timestamp;
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_cursor ON messages (conversation_id, server_timestamp DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_messages_thread_cursor ON messages (thread_root_id, server_timestamp DESC, id DESC) WHERE thread_root_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_messages_search ON messages USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_messages_expires_at ON messages (expires_at) WHERE expires_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_attachments_message ON attachments (message_id);
+CREATE INDEX IF NOT EXISTS idx_attachments_storage_key ON attachments (storage_key);
+CREATE INDEX IF NOT EXISTS idx_message_revisions_message ON message_revisions (message_id, id);
+CREATE INDEX IF NOT EXISTS idx_pinned_messages_conversation ON pinned_messages (conversation_id, pinned_at DESC);
+CREATE INDEX IF NOT EXISTS idx_bookmarks_user_created ON bookmarks (user_id, created_at DESC, message_id DESC);
+CREATE INDEX IF NOT EXISTS idx_bookmarks_message ON bookmarks (message_id);
+CREATE INDEX IF NOT EXISTS idx_bookmarks_conversation_user ON bookmarks (conversation_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_scheduled_messages_send_at ON scheduled_messages (send_at);
+CREATE INDEX IF NOT EXISTS idx_scheduled_messages_sender ON scheduled_messages (sender_id, send_at);
 CREATE INDEX IF NOT EXISTS idx_friendships_user1 ON friendships(user_id1);
 CREATE INDEX IF NOT EXISTS idx_friendships_user2 ON friendships(user_id2);
 CREATE INDEX IF NOT EXISTS idx_groups_owner ON groups(owner_id);
@@ -77,3 +235,6 @@ CREATE INDEX IF NOT EXISTS idx_games_player1 ON games(player1_id);
 CREATE INDEX IF NOT EXISTS idx_games_player2 ON games(player2_id);
 CREATE INDEX IF NOT EXISTS idx_games_initiator ON games(initiator_id);
 CREATE INDEX IF NOT EXISTS idx_events_user_timestamp ON events (user_id, server_timestamp DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_events_user_seq ON events (user_id, seq);
+-- Finds the events carrying a message's content (the message, or a pin's message) when it expires
+CREATE INDEX IF NOT EXISTS idx_events_message ON events ((COALESCE(payload->>'id', payload->'message'->>'id')));
@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"real-time-chat/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresAttachmentRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresAttachmentRepository(db *pgxpool.Pool) domain.AttachmentRepository {
+	return &PostgresAttachmentRepository{db: db}
+}
+
+const attachmentSelect = `
+		SELECT a.id, a.conversation_id, a.uploader_id, a.message_id, a.file_name, a.mime_type,
+			a.size_bytes, a.width, a.height, a.checksum, a.storage_key, a.created_at
+		FROM attachments a`
+
+func scanAttachment(row pgx.Row) (*domain.Attachment, error) {
+	var a domain.Attachment
+	err := row.Scan(&a.ID, &a.ConversationID, &a.UploaderID, &a.MessageID, &a.FileName, &a.MimeType,
+		&a.SizeBytes, &a.Width, &a.Height, &a.Checksum, &a.StorageKey, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *PostgresAttachmentRepository) Create(ctx context.Context, a *domain.Attachment) error {
+	return insertAttachment(ctx, r.db, a)
+}
+
+func insertAttachment(ctx context.Context, q querier, a *domain.Attachment) error {
+	query := `
+		INSERT INTO attachments (id, conversation_id, uploader_id, message_id, file_name, mime_type, size_bytes, width, height, checksum, storage_key, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+	_, err := q.Exec(ctx, query, a.ID, a.ConversationID, a.UploaderID, a.MessageID, a.FileName, a.MimeType,
+		a.SizeBytes, a.Width, a.Height, a.Checksum, a.StorageKey, a.CreatedAt)
+	return err
+}
+
+func (r *PostgresAttachmentRepository) FindByID(ctx context.Context, attachmentID string) (*domain.Attachment, error) {
+	query := attachmentSelect + `
+		WHERE a.id = $1
+			AND NOT EXISTS (SELECT 1 FROM messages m WHERE m.id = a.message_id AND m.deleted_at IS NOT NULL)`
+	a, err := scanAttachment(r.db.QueryRow(ctx, query, attachmentID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("attachment not found")
+		}
+		return nil, err
+	}
+	return a, nil
+}
+
+// linkAttachments attaches unsent uploads to a message. It fails with
+// ErrAttachmentAlreadySent unless every one of attachmentIDs was linked.
+func linkAttachments(ctx context.Context, q querier, attachmentIDs []string, messageID string) error {
+	query := `UPDATE attachments SET message_id = $1 WHERE id = ANY($2::uuid[]) AND message_id IS NULL`
+	tag, err := q.Exec(ctx, query, messageID, attachmentIDs)
+	if err != nil {
+		return err
+	}
+	if int(tag.RowsAffected()) != len(attachmentIDs) {
+		return domain.ErrAttachmentAlreadySent
+	}
+	return nil
+}
+
+func (r *PostgresAttachmentRepository) GetForMessages(ctx context.Context, messageIDs []string) (map[string][]*domain.Attachment, error) {
+	attachments := make(map[string][]*domain.Attachment)
+	if len(messageIDs) == 0 {
+		return attachments, nil
+	}
+
+	query := attachmentSelect + `
+		WHERE a.message_id = ANY($1::uuid[])
+			AND NOT EXISTS (SELECT 1 FROM messages m WHERE m.id = a.message_id AND m.deleted_at IS NOT NULL)
+		ORDER BY a.created_at ASC`
+	rows, err := r.db.Query(ctx, query, messageIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments[*a.MessageID] = append(attachments[*a.MessageID], a)
+	}
+	return attachments, rows.Err()
+}
+
+func (r *PostgresAttachmentRepository) DeleteForMessages(ctx context.Context, messageIDs []string) ([]string, error) {
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
+
+	// The outer query still sees the deleted rows, so they're excluded by hand
+	query := `
+		WITH deleted AS (
+			DELETE FROM attachments WHERE message_id = ANY($1::uuid[]) RETURNING storage_key
+		)
+		SELECT DISTINCT d.storage_key FROM deleted d
+		WHERE NOT EXISTS (
+			SELECT 1 FROM attachments a
+			WHERE a.storage_key = d.storage_key AND (a.message_id IS NULL OR a.message_id <> ALL($1::uuid[]))
+		)`
+	rows, err := r.db.Query(ctx, query, messageIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
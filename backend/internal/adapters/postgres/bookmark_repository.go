@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"real-time-chat/internal/domain"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresBookmarkRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresBookmarkRepository(db *pgxpool.Pool) domain.BookmarkRepository {
+	return &PostgresBookmarkRepository{db: db}
+}
+
+func (r *PostgresBookmarkRepository) Save(ctx context.Context, userID string, bookmark *domain.Bookmark) error {
+	query := `
+		INSERT INTO bookmarks (user_id, message_id, conversation_id, note, created_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, message_id) DO UPDATE SET note = EXCLUDED.note
+		RETURNING created_at`
+	return r.db.QueryRow(ctx, query, userID, bookmark.Message.ID, bookmark.Message.ConversationID, bookmark.Note, bookmark.CreatedAt).
+		Scan(&bookmark.CreatedAt)
+}
+
+func (r *PostgresBookmarkRepository) Delete(ctx context.Context, userID, messageID string) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM bookmarks WHERE user_id = $1 AND message_id = $2`, userID, messageID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *PostgresBookmarkRepository) DeleteForMessage(ctx context.Context, messageID string) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM bookmarks WHERE message_id = $1`, messageID)
+	return err
+}
+
+func (r *PostgresBookmarkRepository) DeleteForConversation(ctx context.Context, conversationID, userID string) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM bookmarks WHERE conversation_id = $1 AND user_id = $2`, conversationID, userID)
+	return err
+}
+
+func (r *PostgresBookmarkRepository) FindForUser(ctx context.Context, userID string, before *domain.MessageCursor, limit int) ([]*domain.Bookmark, error) {
+	// The participant join hides bookmarks the moment access is lost, even
+	// before DeleteForConversation has cleaned them up.
+	query := `
+		SELECT` + messageColumns + `, b.note, b.created_at
+		FROM bookmarks b
+		JOIN messages m ON m.id = b.message_id
+		JOIN users u ON m.sender_id = u.id
+		JOIN conversation_participants cp ON cp.conversation_id = b.conversation_id AND cp.user_id = b.user_id
+		WHERE b.user_id = $1 AND m.deleted_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.message_id = m.id AND h.user_id = $1)`
+	args := []interface{}{userID, limit}
+	if before != nil {
+		query += ` AND (b.created_at, b.message_id) < ($3, $4)`
+		args = append(args, before.Timestamp, before.ID)
+	}
+	query += ` ORDER BY b.created_at DESC, b.message_id DESC LIMIT $2`
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bookmarks []*domain.Bookmark
+	for rows.Next() {
+		bookmark := &domain.Bookmark{}
+		bookmark.Message, err = scanMessage(rows, &bookmark.Note, &bookmark.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		bookmark.Cursor = domain.MessageCursor{Timestamp: bookmark.CreatedAt, ID: bookmark.Message.ID}.String()
+		bookmarks = append(bookmarks, bookmark)
+	}
+	return bookmarks, rows.Err()
+}
@@ -76,9 +76,11 @@ func (r *PostgresConversationRepository) FindForUser(ctx context.Context, userID
 			SELECT conversation_id, last_read_timestamp FROM conversation_participants WHERE user_id = $1
 		),
 		RankedMessages AS (
-			SELECT m.*, ROW_NUMBER() OVER(PARTITION BY conversation_id ORDER BY server_timestamp DESC) as rn
+			SELECT m.*, ROW_NUMBER() OVER(PARTITION BY conversation_id ORDER BY server_timestamp DESC, id DESC) as rn
 			FROM messages m
 			WHERE m.conversation_id IN (SELECT conversation_id FROM UserConversations)
+				AND m.thread_root_id IS NULL -- Thread replies stay out of the preview, as out of the timeline
+				AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.message_id = m.id AND h.user_id = $1)
 		),
 		LastMessages AS (
 			SELECT rm.*, u.username as sender_username, u.profile_picture_url as sender_profile_picture_url FROM RankedMessages rm
@@ -86,12 +88,13 @@ func (r *PostgresConversationRepository) FindForUser(ctx context.Context, userID
 			WHERE rm.rn = 1
 		)
 		SELECT 
-			c.id, c.type, c.created_at,
-			lm.id, lm.sender_id, lm.content, lm.server_timestamp,
+			c.id, c.type, c.created_at, COALESCE(c.message_ttl_seconds, 0),
+			lm.id, lm.sender_id, lm.content, lm.system, lm.server_timestamp, lm.edited_at, lm.deleted_at,
 			lm.sender_username, lm.sender_profile_picture_url,
 			uc.last_read_timestamp,
 			(SELECT COUNT(m_unread.id) FROM messages m_unread 
-			 WHERE m_unread.conversation_id = c.id AND m_unread.server_timestamp > uc.last_read_timestamp) as unread_count,
+			 WHERE m_unread.conversation_id = c.id AND m_unread.thread_root_id IS NULL
+				AND m_unread.server_timestamp > uc.last_read_timestamp) as unread_count,
 			g.name as group_name, g.slug as group_slug, g.owner_id as group_owner_id, g.created_at as group_created_at
 		FROM conversations c
 		JOIN UserConversations uc ON c.id = uc.conversation_id
@@ -115,13 +118,15 @@ func (r *PostgresConversationRepository) FindForUser(ctx context.Context, userID
 
 		var lastMessageID, lastMessageSenderID, lastMessageContent, senderUsername, senderProfilePictureURL pgtype.Text
 		var lastMessageTimestamp pgtype.Timestamp
+		var lastMessageEditedAt, lastMessageDeletedAt pgtype.Timestamptz
+		var lastMessageSystem pgtype.Bool
 		var unreadCount pgtype.Int4
 		var groupName, groupSlug, groupOwnerID pgtype.Text
 		var groupCreatedAt pgtype.Timestamp
 
 		err := rows.Scan(
-			&convo.ID, &convo.Type, &convo.CreatedAt,
-			&lastMessageID, &lastMessageSenderID, &lastMessageContent, &lastMessageTimestamp,
+			&convo.ID, &convo.Type, &convo.CreatedAt, &convo.MessageTTLSeconds,
+			&lastMessageID, &lastMessageSenderID, &lastMessageContent, &lastMessageSystem, &lastMessageTimestamp, &lastMessageEditedAt, &lastMessageDeletedAt,
 			&senderUsername, &senderProfilePictureURL,
 			&lastReadTimestamp,
 			&unreadCount,
@@ -135,7 +140,14 @@ func (r *PostgresConversationRepository) FindForUser(ctx context.Context, userID
 			lastMessage.ID = lastMessageID.String
 			lastMessage.SenderID = lastMessageSenderID.String
 			lastMessage.Content = lastMessageContent.String
+			lastMessage.System = lastMessageSystem.Bool
 			lastMessage.ServerTimestamp = lastMessageTimestamp.Time
+			if lastMessageEditedAt.Valid {
+				lastMessage.EditedAt = &lastMessageEditedAt.Time
+			}
+			if lastMessageDeletedAt.Valid {
+				lastMessage.DeletedAt = &lastMessageDeletedAt.Time
+			}
 			sender.ID = lastMessageSenderID.String
 			sender.Username = senderUsername.String
 			sender.ProfilePictureURL = senderProfilePictureURL.String
@@ -160,9 +172,9 @@ func (r *PostgresConversationRepository) FindForUser(ctx context.Context, userID
 }
 
 func (r *PostgresConversationRepository) FindByID(ctx context.Context, conversationID string) (*domain.Conversation, error) {
-	query := `SELECT id, type, created_at FROM conversations WHERE id = $1`
+	query := `SELECT id, type, created_at, COALESCE(message_ttl_seconds, 0) FROM conversations WHERE id = $1`
 	var convo domain.Conversation
-	err := r.db.QueryRow(ctx, query, conversationID).Scan(&convo.ID, &convo.Type, &convo.CreatedAt)
+	err := r.db.QueryRow(ctx, query, conversationID).Scan(&convo.ID, &convo.Type, &convo.CreatedAt, &convo.MessageTTLSeconds)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, errors.New("conversation not found")
@@ -192,14 +204,66 @@ func (r *PostgresConversationRepository) FindOneToOne(ctx context.Context, userI
 	return conversationID, nil
 }
 
-func (r *PostgresConversationRepository) UpdateLastRead(ctx context.Context, conversationID, userID string) error {
+func (r *PostgresConversationRepository) AdvanceReceipt(ctx context.Context, conversationID, userID string, status domain.ReceiptStatus, through time.Time) ([]string, error) {
+	// Reading implies delivery, so both watermarks move on read. The old
+	// watermark is locked and read first so each newly covered message is
+	// reported once even when acks race.
 	query := `
-		UPDATE conversation_participants
-		SET last_read_timestamp = NOW()
-		WHERE conversation_id = $1 AND user_id = $2
-	`
-	_, err := r.db.Exec(ctx, query, conversationID, userID)
-	return err
+		WITH old AS (
+			SELECT COALESCE(CASE WHEN $4 THEN last_read_timestamp ELSE last_delivered_timestamp END, '-infinity') AS through
+			FROM conversation_participants
+			WHERE conversation_id = $1 AND user_id = $2
+			FOR UPDATE
+		), advanced AS (
+			UPDATE conversation_participants SET
+				last_delivered_timestamp = GREATEST(last_delivered_timestamp, $3),
+				last_read_timestamp = CASE WHEN $4 THEN GREATEST(last_read_timestamp, $3) ELSE last_read_timestamp END
+			WHERE conversation_id = $1 AND user_id = $2
+		)
+		SELECT DISTINCT m.sender_id
+		FROM messages m, old
+		WHERE m.conversation_id = $1 AND m.sender_id <> $2
+			AND m.server_timestamp > old.through AND m.server_timestamp <= $3`
+	rows, err := r.db.Query(ctx, query, conversationID, userID, through, status == domain.ReceiptRead)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var senderIDs []string
+	for rows.Next() {
+		var senderID string
+		if err := rows.Scan(&senderID); err != nil {
+			return nil, err
+		}
+		senderIDs = append(senderIDs, senderID)
+	}
+	return senderIDs, rows.Err()
+}
+
+func (r *PostgresConversationRepository) GetReceipts(ctx context.Context, conversationID string) ([]*domain.ParticipantReceipt, error) {
+	query := `
+		SELECT u.id, u.username, COALESCE(u.profile_picture_url, ''),
+			COALESCE(GREATEST(cp.last_delivered_timestamp, cp.last_read_timestamp), to_timestamp(0)),
+			COALESCE(cp.last_read_timestamp, to_timestamp(0))
+		FROM conversation_participants cp
+		JOIN users u ON u.id = cp.user_id
+		WHERE cp.conversation_id = $1`
+	rows, err := r.db.Query(ctx, query, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var receipts []*domain.ParticipantReceipt
+	for rows.Next() {
+		receipt := &domain.ParticipantReceipt{User: &domain.User{}}
+		if err := rows.Scan(&receipt.User.ID, &receipt.User.Username, &receipt.User.ProfilePictureURL, &receipt.DeliveredThrough, &receipt.ReadThrough); err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, receipt)
+	}
+	return receipts, rows.Err()
 }
 
 func (r *PostgresConversationRepository) Delete(ctx context.Context, conversationID string) error {
@@ -220,3 +284,8 @@ func (r *PostgresConversationRepository) GetLastReadTimestamp(ctx context.Contex
 	}
 	return lastRead, nil
 }
+
+func (r *PostgresConversationRepository) SetMessageTTL(ctx context.Context, conversationID string, ttlSeconds int) error {
+	_, err := r.db.Exec(ctx, `UPDATE conversations SET message_ttl_seconds = NULLIF($2, 0) WHERE id = $1`, conversationID, ttlSeconds)
+	return err
+}
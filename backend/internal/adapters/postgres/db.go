@@ -6,9 +6,18 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// querier is implemented by both *pgxpool.Pool and pgx.Tx, so a statement can
+// run on its own or as part of a transaction.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
 func NewDBPool(databaseUrl string) (*pgxpool.Pool, error) {
 	pool, err := pgxpool.New(context.Background(), databaseUrl)
 	if err != nil {
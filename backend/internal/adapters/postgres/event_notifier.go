@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"context"
+	"log"
+	"real-time-chat/internal/domain"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// userEventsChannel is notified by the events insert trigger with the user ID as payload.
+const userEventsChannel = "user_events"
+
+type PostgresEventNotifier struct {
+	db      *pgxpool.Pool
+	mu      sync.Mutex
+	waiters map[string]map[chan struct{}]bool // userID -> wake channels
+}
+
+func NewPostgresEventNotifier(db *pgxpool.Pool) domain.EventNotifier {
+	return &PostgresEventNotifier{db: db, waiters: make(map[string]map[chan struct{}]bool)}
+}
+
+func (n *PostgresEventNotifier) Subscribe(userID string) (<-chan struct{}, func()) {
+	wake := make(chan struct{}, 1)
+	n.mu.Lock()
+	if n.waiters[userID] == nil {
+		n.waiters[userID] = make(map[chan struct{}]bool)
+	}
+	n.waiters[userID][wake] = true
+	n.mu.Unlock()
+
+	return wake, func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		delete(n.waiters[userID], wake)
+		if len(n.waiters[userID]) == 0 {
+			delete(n.waiters, userID)
+		}
+	}
+}
+
+// Listen holds a dedicated connection on LISTEN until ctx is done, reconnecting on failure.
+func (n *PostgresEventNotifier) Listen(ctx context.Context) {
+	for {
+		err := n.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("event notifier connection lost, reconnecting: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (n *PostgresEventNotifier) listen(ctx context.Context) error {
+	conn, err := n.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		conn.Exec(context.Background(), "UNLISTEN *")
+		conn.Release()
+	}()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+userEventsChannel); err != nil {
+		return err
+	}
+	// Notifications sent while we were not listening are lost, so let every waiter re-check.
+	n.wakeAll()
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		n.wake(notification.Payload)
+	}
+}
+
+func (n *PostgresEventNotifier) wake(userID string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for ch := range n.waiters[userID] {
+		signal(ch)
+	}
+}
+
+func (n *PostgresEventNotifier) wakeAll() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, chans := range n.waiters {
+		for ch := range chans {
+			signal(ch)
+		}
+	}
+}
+
+// signal does a non-blocking send; a pending wakeup already covers this one.
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"real-time-chat/internal/domain"
-	"strconv"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -19,36 +18,33 @@ func NewPostgresEventRepository(db *pgxpool.Pool) domain.EventRepository {
 }
 
 func (r *PostgresEventRepository) Create(ctx context.Context, event *domain.Event) error {
-	query := `INSERT INTO events (id, user_id, event_type, payload, server_timestamp) VALUES ($1, $2, $3, $4, $5)`
-	_, err := r.db.Exec(ctx, query, event.ID, event.UserID, event.EventType, event.Payload, event.ServerTimestamp)
-	return err
+	// Allocate the user's next sequence number and insert in one statement, so the
+	// counter row stays locked until the event commits.
+	query := `
+		WITH next_seq AS (
+			INSERT INTO user_event_sequences (user_id, last_seq) VALUES ($2, 1)
+			ON CONFLICT (user_id) DO UPDATE SET last_seq = user_event_sequences.last_seq + 1
+			RETURNING last_seq
+		)
+		INSERT INTO events (id, user_id, seq, event_type, payload, server_timestamp)
+		SELECT $1, $2, last_seq, $3, $4, $5 FROM next_seq
+		RETURNING seq`
+	return r.db.QueryRow(ctx, query, event.ID, event.UserID, event.EventType, event.Payload, event.ServerTimestamp).Scan(&event.Seq)
 }
 
-func (r *PostgresEventRepository) GetEventsForUser(ctx context.Context, userID string, sinceEventID string, limit int) ([]*domain.Event, error) {
-	baseQuery := `SELECT id, user_id, event_type, payload, server_timestamp FROM events WHERE user_id = $1`
+func (r *PostgresEventRepository) GetEventsForUser(ctx context.Context, userID string, sinceSeq int64, limit int) ([]*domain.Event, error) {
+	query := `SELECT id, user_id, seq, event_type, payload, server_timestamp FROM events WHERE user_id = $1`
 	args := []interface{}{userID}
-	argCount := 1
-
-	if sinceEventID != "" {
-		// First, get the timestamp of the sinceEventID
-		var sinceTimestamp string
-		timestampQuery := `SELECT server_timestamp FROM events WHERE id = $1`
-		err := r.db.QueryRow(ctx, timestampQuery, sinceEventID).Scan(&sinceTimestamp)
-		if err != nil {
-			if errors.Is(err, pgx.ErrNoRows) {
-				return nil, errors.New("since_event_id not found")
-			}
-			return nil, err
-		}
-		baseQuery += ` AND server_timestamp > $` + (strconv.Itoa(argCount + 1)) + ` ORDER BY server_timestamp ASC LIMIT $` + (strconv.Itoa(argCount + 2))
-		args = append(args, sinceTimestamp, limit)
+	if sinceSeq > 0 {
+		query += ` AND seq > $2 ORDER BY seq ASC LIMIT $3`
+		args = append(args, sinceSeq, limit)
 	} else {
-		baseQuery += ` ORDER BY server_timestamp DESC LIMIT $` + (strconv.Itoa(argCount + 1))
+		// No cursor: fetch the latest events and reverse them below.
+		query += ` ORDER BY seq DESC LIMIT $2`
 		args = append(args, limit)
-		// If no sinceEventID, we fetch latest events and then reverse them later.
 	}
 
-	rows, err := r.db.Query(ctx, baseQuery, args...)
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -57,15 +53,15 @@ func (r *PostgresEventRepository) GetEventsForUser(ctx context.Context, userID s
 	var events []*domain.Event
 	for rows.Next() {
 		var event domain.Event
-		err := rows.Scan(&event.ID, &event.UserID, &event.EventType, &event.Payload, &event.ServerTimestamp)
+		err := rows.Scan(&event.ID, &event.UserID, &event.Seq, &event.EventType, &event.Payload, &event.ServerTimestamp)
 		if err != nil {
 			return nil, err
 		}
 		events = append(events, &event)
 	}
 
-	if sinceEventID == "" {
-		// If we fetched the latest events (no sinceEventID), reverse to get chronological order
+	if sinceSeq <= 0 {
+		// If we fetched the latest events (no cursor), reverse to get chronological order
 		for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
 			events[i], events[j] = events[j], events[i]
 		}
@@ -75,9 +71,9 @@ func (r *PostgresEventRepository) GetEventsForUser(ctx context.Context, userID s
 }
 
 func (r *PostgresEventRepository) GetEventByID(ctx context.Context, eventID string) (*domain.Event, error) {
-	query := `SELECT id, user_id, event_type, payload, server_timestamp FROM events WHERE id = $1`
+	query := `SELECT id, user_id, seq, event_type, payload, server_timestamp FROM events WHERE id = $1`
 	var event domain.Event
-	err := r.db.QueryRow(ctx, query, eventID).Scan(&event.ID, &event.UserID, &event.EventType, &event.Payload, &event.ServerTimestamp)
+	err := r.db.QueryRow(ctx, query, eventID).Scan(&event.ID, &event.UserID, &event.Seq, &event.EventType, &event.Payload, &event.ServerTimestamp)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, errors.New("event not found")
@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"real-time-chat/internal/domain"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -63,7 +64,7 @@ func (r *PostgresFriendshipRepository) GetPendingRequests(ctx context.Context, u
 
 func (r *PostgresFriendshipRepository) GetFriends(ctx context.Context, userID string) ([]*domain.User, error) {
 	query := `
-		SELECT u.id, u.username, u.profile_picture_url
+		SELECT u.id, u.username, u.profile_picture_url, ` + userStatusColumns + `
 		FROM users u
 		WHERE u.id IN (
 			SELECT user_id2 FROM friendships WHERE user_id1 = $1 AND status = 'accepted'
@@ -78,13 +79,15 @@ func (r *PostgresFriendshipRepository) GetFriends(ctx context.Context, userID st
 	}
 	defer rows.Close()
 
+	now := time.Now()
 	var friends []*domain.User
 	for rows.Next() {
 		var friend domain.User
-		err := rows.Scan(&friend.ID, &friend.Username, &friend.ProfilePictureURL)
-		if err != nil {
+		dest := append([]interface{}{&friend.ID, &friend.Username, &friend.ProfilePictureURL}, userStatusFields(&friend)...)
+		if err := rows.Scan(dest...); err != nil {
 			return nil, err
 		}
+		friend.Status.Resolve(now)
 		friends = append(friends, &friend)
 	}
 	return friends, nil
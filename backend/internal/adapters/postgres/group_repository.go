@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"real-time-chat/internal/domain"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -51,7 +52,7 @@ func (r *PostgresGroupRepository) FindBySlug(ctx context.Context, slug string) (
 
 func (r *PostgresGroupRepository) GetMembers(ctx context.Context, groupID string) ([]*domain.User, error) {
 	query := `
-		SELECT u.id, u.username, u.profile_picture_url
+		SELECT u.id, u.username, u.profile_picture_url, ` + userStatusColumns + `
 		FROM users u
 		JOIN conversation_participants cp ON u.id = cp.user_id
 		WHERE cp.conversation_id = $1`
@@ -61,12 +62,15 @@ func (r *PostgresGroupRepository) GetMembers(ctx context.Context, groupID string
 	}
 	defer rows.Close()
 
+	now := time.Now()
 	var members []*domain.User
 	for rows.Next() {
 		var member domain.User
-		if err := rows.Scan(&member.ID, &member.Username, &member.ProfilePictureURL); err != nil {
+		dest := append([]interface{}{&member.ID, &member.Username, &member.ProfilePictureURL}, userStatusFields(&member)...)
+		if err := rows.Scan(dest...); err != nil {
 			return nil, err
 		}
+		member.Status.Resolve(now)
 		members = append(members, &member)
 	}
 	return members, nil
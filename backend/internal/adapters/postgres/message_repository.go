@@ -3,10 +3,13 @@ package postgres
 import (
 	"context"
 	"errors"
+	"fmt"
 	"real-time-chat/internal/domain"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -18,73 +21,407 @@ func NewPostgresMessageRepository(db *pgxpool.Pool) domain.MessageRepository {
 	return &PostgresMessageRepository{db: db}
 }
 
+// messageColumns are the columns of a message (m) and its sender (u), in the
+// order scanMessage reads them.
+const messageColumns = `
+			m.id, m.conversation_id, m.sender_id, m.content, m.system, m.server_timestamp, m.edited_at, m.deleted_at,
+			m.expires_at, m.reply_to_id, m.thread_root_id,
+			m.forwarded_from_message_id, m.forwarded_from_conversation_id, m.forwarded_from_sender_id,
+			(SELECT fu.username FROM users fu WHERE fu.id = m.forwarded_from_sender_id),
+			(SELECT COUNT(*) FROM messages r WHERE r.thread_root_id = m.id AND r.deleted_at IS NULL),
+			u.id, u.username, u.profile_picture_url`
+
+// messageSelect selects a message joined with its sender; scan rows with scanMessage.
+const messageSelect = `
+		SELECT` + messageColumns + `
+		FROM messages m
+		JOIN users u ON m.sender_id = u.id`
+
+// scanMessage reads messageColumns, followed by any extra columns into extra.
+func scanMessage(row pgx.Row, extra ...interface{}) (*domain.Message, error) {
+	var msg domain.Message
+	var sender domain.User
+	msg.Sender = &sender
+	var originMessageID, originConversationID, originSenderID, originSenderUsername pgtype.Text
+	dest := []interface{}{
+		&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Content, &msg.System, &msg.ServerTimestamp, &msg.EditedAt, &msg.DeletedAt,
+		&msg.ExpiresAt, &msg.ReplyToID, &msg.ThreadRootID,
+		&originMessageID, &originConversationID, &originSenderID, &originSenderUsername,
+		&msg.ThreadReplyCount,
+		&sender.ID, &sender.Username, &sender.ProfilePictureURL,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	if originMessageID.Valid {
+		msg.ForwardedFrom = &domain.MessageOrigin{
+			MessageID:      originMessageID.String,
+			ConversationID: originConversationID.String,
+			SenderID:       originSenderID.String,
+			SenderUsername: originSenderUsername.String,
+		}
+	}
+	return &msg, nil
+}
+
 func (r *PostgresMessageRepository) Create(ctx context.Context, message *domain.Message) error {
-	query := `INSERT INTO messages (id, conversation_id, sender_id, content, server_timestamp) 
-              VALUES ($1, $2, $3, $4, $5)`
-	_, err := r.db.Exec(ctx, query, message.ID, message.ConversationID, message.SenderID, message.Content, message.ServerTimestamp)
-	return err
+	return insertMessage(ctx, r.db, message)
+}
+
+// CreateWithAttachments inserts the message and links the uploads to it in
+// one transaction, so a failed link leaves no message behind.
+func (r *PostgresMessageRepository) CreateWithAttachments(ctx context.Context, message *domain.Message, attachmentIDs []string) error {
+	if len(attachmentIDs) == 0 {
+		return insertMessage(ctx, r.db, message)
+	}
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := insertMessage(ctx, tx, message); err != nil {
+		return err
+	}
+	if err := linkAttachments(ctx, tx, attachmentIDs, message.ID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// CreateForwarded inserts forwarded copies together with their attachments,
+// all or nothing.
+func (r *PostgresMessageRepository) CreateForwarded(ctx context.Context, messages []*domain.Message) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, message := range messages {
+		if err := insertMessage(ctx, tx, message); err != nil {
+			return err
+		}
+		for _, attachment := range message.Attachments {
+			if err := insertAttachment(ctx, tx, attachment); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// insertMessage inserts the message. Its expiry is fixed from the
+// conversation's TTL in the same statement, so changing the TTL only affects
+// later messages.
+func insertMessage(ctx context.Context, q querier, message *domain.Message) error {
+	// A thread reply never outlives its root, so the two expire together
+	query := `INSERT INTO messages (id, conversation_id, sender_id, content, server_timestamp, reply_to_id, thread_root_id, system,
+                forwarded_from_message_id, forwarded_from_conversation_id, forwarded_from_sender_id, expires_at) 
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11,
+                LEAST((SELECT $5::timestamptz + make_interval(secs => message_ttl_seconds) FROM conversations WHERE id = $2),
+                  (SELECT expires_at FROM messages WHERE id = $7)))
+              RETURNING expires_at`
+	var originMessageID, originConversationID, originSenderID *string
+	if origin := message.ForwardedFrom; origin != nil {
+		originMessageID, originConversationID, originSenderID = &origin.MessageID, &origin.ConversationID, &origin.SenderID
+	}
+	return q.QueryRow(ctx, query, message.ID, message.ConversationID, message.SenderID, message.Content, message.ServerTimestamp,
+		message.ReplyToID, message.ThreadRootID, message.System, originMessageID, originConversationID, originSenderID).Scan(&message.ExpiresAt)
+}
+
+func (r *PostgresMessageRepository) FindByID(ctx context.Context, messageID string) (*domain.Message, error) {
+	msg, err := scanMessage(r.db.QueryRow(ctx, messageSelect+` WHERE m.id = $1`, messageID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("message not found")
+		}
+		return nil, err
+	}
+	return msg, nil
+}
+
+const (
+	mainTimelineScope = `m.conversation_id = $1 AND m.thread_root_id IS NULL`
+	threadScope       = `m.thread_root_id = $1`
+)
+
+func (r *PostgresMessageRepository) FindByConversationID(ctx context.Context, conversationID, userID string, before *domain.MessageCursor, limit int) ([]*domain.Message, error) {
+	return r.findPage(ctx, mainTimelineScope, conversationID, userID, before, false, limit)
+}
+
+func (r *PostgresMessageRepository) FindByConversationIDAfter(ctx context.Context, conversationID, userID string, after domain.MessageCursor, limit int) ([]*domain.Message, error) {
+	return r.findPage(ctx, mainTimelineScope, conversationID, userID, &after, true, limit)
+}
+
+func (r *PostgresMessageRepository) FindThreadReplies(ctx context.Context, rootMessageID, userID string, before *domain.MessageCursor, limit int) ([]*domain.Message, error) {
+	return r.findPage(ctx, threadScope, rootMessageID, userID, before, false, limit)
+}
+
+func (r *PostgresMessageRepository) FindThreadRepliesAfter(ctx context.Context, rootMessageID, userID string, after domain.MessageCursor, limit int) ([]*domain.Message, error) {
+	return r.findPage(ctx, threadScope, rootMessageID, userID, &after, true, limit)
+}
+
+// findPage returns up to limit messages in scope (bound to $1) next to cursor:
+// older ones, or newer ones when forward is set. Messages userID hid are left out.
+func (r *PostgresMessageRepository) findPage(ctx context.Context, scope, scopeID, userID string, cursor *domain.MessageCursor, forward bool, limit int) ([]*domain.Message, error) {
+	comparison, order := "<", "DESC"
+	if forward {
+		comparison, order = ">", "ASC"
+	}
+	query := messageSelect + `
+		WHERE ` + scope + `
+			AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.message_id = m.id AND h.user_id = $2)`
+	args := []interface{}{scopeID, userID, limit}
+	if cursor != nil {
+		query += ` AND (m.server_timestamp, m.id) ` + comparison + ` ($4, $5)`
+		args = append(args, cursor.Timestamp, cursor.ID)
+	}
+	query += ` ORDER BY m.server_timestamp ` + order + `, m.id ` + order + ` LIMIT $3`
+
+	messages, err := r.queryMessages(ctx, query, args...)
+	if err != nil || forward {
+		return messages, err
+	}
+	// Reverse slice to return messages in chronological order
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
 }
 
-func (r *PostgresMessageRepository) FindByConversationID(ctx context.Context, conversationID string, before time.Time, limit int) ([]*domain.Message, error) {
+// Search finds messages matching filter.Query in conversations the user takes
+// part in, newest first, continuing from before when it is set. Deleted and
+// hidden messages are skipped.
+func (r *PostgresMessageRepository) Search(ctx context.Context, userID string, filter domain.MessageSearchFilter, before *domain.MessageCursor, limit int) ([]*domain.MessageSearchHit, error) {
+	// Content is HTML-escaped before highlighting so only our <mark> tags are markup
 	query := `
-		SELECT m.id, m.conversation_id, m.sender_id, m.content, m.server_timestamp, u.id, u.username, u.profile_picture_url
+		SELECT` + messageColumns + `,
+			ts_headline('simple', replace(replace(replace(m.content, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), q,
+				'StartSel=<mark>, StopSel=</mark>, MinWords=10, MaxWords=30, MaxFragments=2')
 		FROM messages m
 		JOIN users u ON m.sender_id = u.id
-		WHERE m.conversation_id = $1 AND m.server_timestamp < $2
-		ORDER BY m.server_timestamp DESC
-		LIMIT $3`
+		JOIN conversation_participants cp ON cp.conversation_id = m.conversation_id AND cp.user_id = $1
+		CROSS JOIN websearch_to_tsquery('simple', $2) q
+		WHERE m.search_vector @@ q AND m.deleted_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.message_id = m.id AND h.user_id = $1)`
+	args := []interface{}{userID, filter.Query}
+	addCondition := func(condition string, values ...interface{}) {
+		for _, v := range values {
+			args = append(args, v)
+			condition = strings.Replace(condition, "?", fmt.Sprintf("$%d", len(args)), 1)
+		}
+		query += " AND " + condition
+	}
+	if filter.ConversationID != "" {
+		addCondition("m.conversation_id = ?", filter.ConversationID)
+	}
+	if filter.SenderID != "" {
+		addCondition("m.sender_id = ?", filter.SenderID)
+	}
+	if !filter.From.IsZero() {
+		addCondition("m.server_timestamp >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("m.server_timestamp < ?", filter.To)
+	}
+	if before != nil {
+		addCondition("(m.server_timestamp, m.id) < (?, ?)", before.Timestamp, before.ID)
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY m.server_timestamp DESC, m.id DESC LIMIT $%d", len(args))
 
-	rows, err := r.db.Query(ctx, query, conversationID, before, limit)
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var messages []*domain.Message
+	var hits []*domain.MessageSearchHit
 	for rows.Next() {
-		var msg domain.Message
-		var sender domain.User
-		msg.Sender = &sender
-		err := rows.Scan(
-			&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Content, &msg.ServerTimestamp,
-			&sender.ID, &sender.Username, &sender.ProfilePictureURL,
-		)
-		if err != nil {
+		var hit domain.MessageSearchHit
+		if hit.Message, err = scanMessage(rows, &hit.Snippet); err != nil {
 			return nil, err
 		}
-		messages = append(messages, &msg)
+		hits = append(hits, &hit)
 	}
+	return hits, rows.Err()
+}
 
-	// Reverse slice to return messages in chronological order
-	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
-		messages[i], messages[j] = messages[j], messages[i]
+// queryMessages runs a messageSelect query and scans every row.
+func (r *PostgresMessageRepository) queryMessages(ctx context.Context, query string, args ...interface{}) ([]*domain.Message, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	return messages, nil
+	var messages []*domain.Message
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
 }
 
 func (r *PostgresMessageRepository) GetLastMessage(ctx context.Context, conversationID string) (*domain.Message, error) {
-	query := `
-		SELECT m.id, m.conversation_id, m.sender_id, m.content, m.server_timestamp, u.id, u.username, u.profile_picture_url
-		FROM messages m
-		JOIN users u ON m.sender_id = u.id
-		WHERE m.conversation_id = $1
-		ORDER BY m.server_timestamp DESC
+	query := messageSelect + `
+		WHERE ` + mainTimelineScope + `
+		ORDER BY m.server_timestamp DESC, m.id DESC
 		LIMIT 1`
 
-	var msg domain.Message
-	var sender domain.User
-	msg.Sender = &sender
-
-	err := r.db.QueryRow(ctx, query, conversationID).Scan(
-		&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Content, &msg.ServerTimestamp,
-		&sender.ID, &sender.Username, &sender.ProfilePictureURL,
-	)
+	msg, err := scanMessage(r.db.QueryRow(ctx, query, conversationID))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil // No messages found
 		}
 		return nil, err
 	}
-	return &msg, nil
+	return msg, nil
+}
+
+// UpdateContent replaces a message's content, keeping the replaced content as a revision.
+func (r *PostgresMessageRepository) UpdateContent(ctx context.Context, messageID, content string, editedAt time.Time) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	revisionQuery := `
+		INSERT INTO message_revisions (message_id, content, created_at)
+		SELECT id, content, COALESCE(edited_at, server_timestamp) FROM messages WHERE id = $1`
+	tag, err := tx.Exec(ctx, revisionQuery, messageID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("message not found")
+	}
+
+	updateQuery := `UPDATE messages SET content = $1, edited_at = $2 WHERE id = $3`
+	if _, err := tx.Exec(ctx, updateQuery, content, editedAt, messageID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *PostgresMessageRepository) GetRevisions(ctx context.Context, messageID string) ([]*domain.MessageRevision, error) {
+	query := `SELECT id, message_id, content, created_at FROM message_revisions WHERE message_id = $1 ORDER BY id ASC`
+	rows, err := r.db.Query(ctx, query, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []*domain.MessageRevision
+	for rows.Next() {
+		var rev domain.MessageRevision
+		if err := rows.Scan(&rev.ID, &rev.MessageID, &rev.Content, &rev.CreatedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, &rev)
+	}
+	return revisions, rows.Err()
+}
+
+// MarkDeleted turns a message into a tombstone: its content and revisions are dropped.
+func (r *PostgresMessageRepository) MarkDeleted(ctx context.Context, messageID string, deletedAt time.Time) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `UPDATE messages SET content = '', deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`, deletedAt, messageID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("message not found or already deleted")
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM message_revisions WHERE message_id = $1`, messageID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *PostgresMessageRepository) HideForUser(ctx context.Context, messageID, userID string) error {
+	query := `INSERT INTO message_hidden (message_id, user_id) VALUES ($1, $2) ON CONFLICT (user_id, message_id) DO NOTHING`
+	_, err := r.db.Exec(ctx, query, messageID, userID)
+	return err
+}
+
+// FindExpired scans idx_messages_expires_at across all conversations, oldest
+// expiry first. The replies of a thread root in the batch are added to it, as
+// they are deleted along with the root.
+func (r *PostgresMessageRepository) FindExpired(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	query := `
+		WITH batch AS (SELECT id FROM messages WHERE expires_at <= $1 ORDER BY expires_at LIMIT $2)
+		SELECT id FROM batch
+		UNION
+		SELECT m.id FROM messages m JOIN batch ON m.thread_root_id = batch.id`
+	rows, err := r.db.Query(ctx, query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messageIDs []string
+	for rows.Next() {
+		var messageID string
+		if err := rows.Scan(&messageID); err != nil {
+			return nil, err
+		}
+		messageIDs = append(messageIDs, messageID)
+	}
+	return messageIDs, rows.Err()
+}
+
+func (r *PostgresMessageRepository) DeleteExpired(ctx context.Context, messageIDs []string) (map[string][]string, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Replies expire no later than their root, so they go with it rather than
+	// being left behind with a NULL thread_root_id.
+	rows, err := tx.Query(ctx, `
+		DELETE FROM messages
+		WHERE (id = ANY($1::uuid[]) OR thread_root_id = ANY($1::uuid[])) AND expires_at IS NOT NULL
+		RETURNING id, conversation_id`, messageIDs)
+	if err != nil {
+		return nil, err
+	}
+	deleted := make(map[string][]string)
+	var deletedIDs []string
+	for rows.Next() {
+		var messageID, conversationID string
+		if err := rows.Scan(&messageID, &conversationID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		deleted[conversationID] = append(deleted[conversationID], messageID)
+		deletedIDs = append(deletedIDs, messageID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Only events holding the message itself (or its pin) carry its content;
+	// the COALESCE matches idx_events_message.
+	eventsQuery := `
+		DELETE FROM events
+		WHERE COALESCE(payload->>'id', payload->'message'->>'id') = ANY($1::text[]) AND event_type = ANY($2::text[])`
+	contentEvents := []string{string(domain.EventNewMessage), string(domain.EventMessageEdited), string(domain.EventMessagePinned)}
+	if _, err := tx.Exec(ctx, eventsQuery, deletedIDs, contentEvents); err != nil {
+		return nil, err
+	}
+	return deleted, tx.Commit(ctx)
 }
@@ -0,0 +1,152 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"real-time-chat/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// newTestDB connects to DATABASE_URL and applies the schema, skipping the test
+// when no database is configured or reachable.
+func newTestDB(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pool, err := pgxpool.New(ctx, databaseURL)
+	if err != nil {
+		t.Skipf("Postgres not available: %v", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		t.Skipf("Postgres not available: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	schema, err := os.ReadFile("../../../db/schema.sql")
+	if err != nil {
+		t.Fatalf("reading schema: %v", err)
+	}
+	if _, err := pool.Exec(ctx, string(schema)); err != nil {
+		t.Fatalf("applying schema: %v", err)
+	}
+	return pool
+}
+
+// newTestUser creates a user that is deleted, with everything it sent, when
+// the test ends.
+func newTestUser(t *testing.T, db *pgxpool.Pool) *domain.User {
+	t.Helper()
+	id := uuid.NewString()
+	user := &domain.User{ID: id, Username: "test_" + id[:8], Email: id + "@example.com", PasswordHash: "x", IsVerified: true}
+	if err := NewPostgresUserRepository(db).Create(context.Background(), user); err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	t.Cleanup(func() { db.Exec(context.Background(), `DELETE FROM users WHERE id = $1`, id) })
+	return user
+}
+
+func TestThreadRepliesExpireWithTheirRoot(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	conversations := NewPostgresConversationRepository(db)
+	messages := NewPostgresMessageRepository(db)
+	events := NewPostgresEventRepository(db)
+
+	user := newTestUser(t, db)
+	conversationID, err := conversations.Create(ctx, &domain.Conversation{ID: uuid.NewString(), Type: domain.TypeOneToOne})
+	if err != nil {
+		t.Fatalf("creating conversation: %v", err)
+	}
+	t.Cleanup(func() { db.Exec(context.Background(), `DELETE FROM conversations WHERE id = $1`, conversationID) })
+	if err := conversations.AddParticipant(ctx, conversationID, user.ID); err != nil {
+		t.Fatalf("adding participant: %v", err)
+	}
+	if err := conversations.SetMessageTTL(ctx, conversationID, 60); err != nil {
+		t.Fatalf("setting TTL: %v", err)
+	}
+
+	// The reply is sent near the end of the root's life, so on its own TTL it
+	// would outlive the root by almost a minute. The thread is dated well in
+	// the past so the root heads the expiry order in a shared database.
+	sentAt := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	root := &domain.Message{ID: uuid.NewString(), ConversationID: conversationID, SenderID: user.ID, Content: "root", ServerTimestamp: sentAt}
+	if err := messages.Create(ctx, root); err != nil {
+		t.Fatalf("creating root: %v", err)
+	}
+	reply := &domain.Message{ID: uuid.NewString(), ConversationID: conversationID, SenderID: user.ID, Content: "reply",
+		ServerTimestamp: sentAt.Add(50 * time.Second), ThreadRootID: &root.ID}
+	if err := messages.Create(ctx, reply); err != nil {
+		t.Fatalf("creating reply: %v", err)
+	}
+	if root.ExpiresAt == nil || reply.ExpiresAt == nil || !reply.ExpiresAt.Equal(*root.ExpiresAt) {
+		t.Fatalf("reply expires at %v, want the root's %v", reply.ExpiresAt, root.ExpiresAt)
+	}
+
+	payload, _ := json.Marshal(reply)
+	if err := events.Create(ctx, &domain.Event{ID: uuid.NewString(), UserID: user.ID, EventType: domain.EventNewMessage,
+		Payload: payload, ServerTimestamp: reply.ServerTimestamp}); err != nil {
+		t.Fatalf("creating event: %v", err)
+	}
+
+	// The reply joins the batch of its root, so its attachments are cleaned up
+	// before the delete cascades to them
+	expired, err := messages.FindExpired(ctx, root.ExpiresAt.Add(time.Second), 1)
+	if err != nil {
+		t.Fatalf("FindExpired: %v", err)
+	}
+	if !containsAll(expired, root.ID, reply.ID) {
+		t.Errorf("FindExpired returned %v, want the root and its reply", expired)
+	}
+
+	// Delete only the root, as when a caller passes part of a thread
+	deleted, err := messages.DeleteExpired(ctx, []string{root.ID})
+	if err != nil {
+		t.Fatalf("DeleteExpired: %v", err)
+	}
+	if got := len(deleted[conversationID]); got != 2 {
+		t.Errorf("DeleteExpired deleted %v, want the root and its reply", deleted)
+	}
+
+	timeline, err := messages.FindByConversationID(ctx, conversationID, user.ID, nil, 50)
+	if err != nil {
+		t.Fatalf("FindByConversationID: %v", err)
+	}
+	if len(timeline) != 0 {
+		t.Errorf("timeline still holds %d messages after the root expired", len(timeline))
+	}
+	if last, err := messages.GetLastMessage(ctx, conversationID); err != nil || last != nil {
+		t.Errorf("GetLastMessage returned %v, %v; want nothing", last, err)
+	}
+
+	var remaining int
+	err = db.QueryRow(ctx, `SELECT COUNT(*) FROM events WHERE user_id = $1 AND payload->>'id' = $2`, user.ID, reply.ID).Scan(&remaining)
+	if err != nil {
+		t.Fatalf("counting events: %v", err)
+	}
+	if remaining != 0 {
+		t.Errorf("%d events still carry the reply's content", remaining)
+	}
+}
+
+func containsAll(ids []string, want ...string) bool {
+	found := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		found[id] = true
+	}
+	for _, id := range want {
+		if !found[id] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"context"
+	"real-time-chat/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresPinRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresPinRepository(db *pgxpool.Pool) domain.PinRepository {
+	return &PostgresPinRepository{db: db}
+}
+
+func (r *PostgresPinRepository) Create(ctx context.Context, pin *domain.PinnedMessage) (bool, error) {
+	// The no-op update makes RETURNING yield the existing row on conflict;
+	// xmax is 0 only for a freshly inserted one.
+	query := `
+		INSERT INTO pinned_messages (message_id, conversation_id, pinned_by, pinned_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (message_id) DO UPDATE SET message_id = EXCLUDED.message_id
+		RETURNING COALESCE(pinned_by::text, ''), pinned_at, xmax = 0`
+	var created bool
+	err := r.db.QueryRow(ctx, query, pin.Message.ID, pin.Message.ConversationID, pin.PinnedBy, pin.PinnedAt).
+		Scan(&pin.PinnedBy, &pin.PinnedAt, &created)
+	return created, err
+}
+
+func (r *PostgresPinRepository) Delete(ctx context.Context, messageID string) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM pinned_messages WHERE message_id = $1`, messageID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *PostgresPinRepository) CountForConversation(ctx context.Context, conversationID string) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM pinned_messages WHERE conversation_id = $1`, conversationID).Scan(&count)
+	return count, err
+}
+
+func (r *PostgresPinRepository) FindForConversation(ctx context.Context, conversationID, userID string) ([]*domain.PinnedMessage, error) {
+	query := `
+		SELECT` + messageColumns + `, COALESCE(p.pinned_by::text, ''), p.pinned_at
+		FROM pinned_messages p
+		JOIN messages m ON m.id = p.message_id
+		JOIN users u ON m.sender_id = u.id
+		WHERE p.conversation_id = $1 AND m.deleted_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.message_id = m.id AND h.user_id = $2)
+		ORDER BY p.pinned_at DESC, p.message_id DESC`
+	rows, err := r.db.Query(ctx, query, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pins []*domain.PinnedMessage
+	for rows.Next() {
+		pin := &domain.PinnedMessage{}
+		pin.Message, err = scanMessage(rows, &pin.PinnedBy, &pin.PinnedAt)
+		if err != nil {
+			return nil, err
+		}
+		pins = append(pins, pin)
+	}
+	return pins, rows.Err()
+}
+
+func (r *PostgresPinRepository) GetPinnedAt(ctx context.Context, messageIDs []string) (map[string]time.Time, error) {
+	pinnedAt := make(map[string]time.Time)
+	if len(messageIDs) == 0 {
+		return pinnedAt, nil
+	}
+
+	rows, err := r.db.Query(ctx, `SELECT message_id, pinned_at FROM pinned_messages WHERE message_id = ANY($1::uuid[])`, messageIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID string
+		var at time.Time
+		if err := rows.Scan(&messageID, &at); err != nil {
+			return nil, err
+		}
+		pinnedAt[messageID] = at
+	}
+	return pinnedAt, rows.Err()
+}
@@ -0,0 +1,57 @@
+package postgres
+
+import (
+	"context"
+	"real-time-chat/internal/domain"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresReactionRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresReactionRepository(db *pgxpool.Pool) domain.ReactionRepository {
+	return &PostgresReactionRepository{db: db}
+}
+
+func (r *PostgresReactionRepository) Add(ctx context.Context, messageID, userID, emoji string) error {
+	query := `INSERT INTO message_reactions (message_id, user_id, emoji) VALUES ($1, $2, $3) ON CONFLICT (message_id, user_id, emoji) DO NOTHING`
+	_, err := r.db.Exec(ctx, query, messageID, userID, emoji)
+	return err
+}
+
+func (r *PostgresReactionRepository) Remove(ctx context.Context, messageID, userID, emoji string) error {
+	query := `DELETE FROM message_reactions WHERE message_id = $1 AND user_id = $2 AND emoji = $3`
+	_, err := r.db.Exec(ctx, query, messageID, userID, emoji)
+	return err
+}
+
+func (r *PostgresReactionRepository) GetForMessages(ctx context.Context, messageIDs []string) (map[string][]*domain.ReactionSummary, error) {
+	summaries := make(map[string][]*domain.ReactionSummary)
+	if len(messageIDs) == 0 {
+		return summaries, nil
+	}
+
+	query := `
+		SELECT message_id, emoji, COUNT(*), array_agg(user_id::text ORDER BY created_at)
+		FROM message_reactions
+		WHERE message_id = ANY($1::uuid[])
+		GROUP BY message_id, emoji
+		ORDER BY message_id, MIN(created_at)`
+	rows, err := r.db.Query(ctx, query, messageIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID string
+		var summary domain.ReactionSummary
+		if err := rows.Scan(&messageID, &summary.Emoji, &summary.Count, &summary.UserIDs); err != nil {
+			return nil, err
+		}
+		summaries[messageID] = append(summaries[messageID], &summary)
+	}
+	return summaries, nil
+}
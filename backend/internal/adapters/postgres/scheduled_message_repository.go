@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"real-time-chat/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresScheduledMessageRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresScheduledMessageRepository(db *pgxpool.Pool) domain.ScheduledMessageRepository {
+	return &PostgresScheduledMessageRepository{db: db}
+}
+
+const scheduledMessageColumns = `id, conversation_id, sender_id, content, send_at, created_at`
+
+func scanScheduledMessage(row pgx.Row) (*domain.ScheduledMessage, error) {
+	var sm domain.ScheduledMessage
+	err := row.Scan(&sm.ID, &sm.ConversationID, &sm.SenderID, &sm.Content, &sm.SendAt, &sm.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &sm, nil
+}
+
+func (r *PostgresScheduledMessageRepository) Create(ctx context.Context, scheduled *domain.ScheduledMessage) error {
+	query := `INSERT INTO scheduled_messages (` + scheduledMessageColumns + `) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := r.db.Exec(ctx, query, scheduled.ID, scheduled.ConversationID, scheduled.SenderID, scheduled.Content,
+		scheduled.SendAt, scheduled.CreatedAt)
+	return err
+}
+
+func (r *PostgresScheduledMessageRepository) FindByID(ctx context.Context, id string) (*domain.ScheduledMessage, error) {
+	query := `SELECT ` + scheduledMessageColumns + ` FROM scheduled_messages WHERE id = $1`
+	return scanScheduledMessage(r.db.QueryRow(ctx, query, id))
+}
+
+func (r *PostgresScheduledMessageRepository) FindForUser(ctx context.Context, userID string) ([]*domain.ScheduledMessage, error) {
+	query := `SELECT ` + scheduledMessageColumns + ` FROM scheduled_messages WHERE sender_id = $1 ORDER BY send_at, id`
+	return r.query(ctx, query, userID)
+}
+
+func (r *PostgresScheduledMessageRepository) Update(ctx context.Context, scheduled *domain.ScheduledMessage, now time.Time) (bool, error) {
+	query := `
+		UPDATE scheduled_messages SET content = $2, send_at = $3
+		WHERE id = $1 AND (claimed_until IS NULL OR claimed_until <= $4)`
+	tag, err := r.db.Exec(ctx, query, scheduled.ID, scheduled.Content, scheduled.SendAt, now)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *PostgresScheduledMessageRepository) Cancel(ctx context.Context, id string, now time.Time) (bool, error) {
+	query := `DELETE FROM scheduled_messages WHERE id = $1 AND (claimed_until IS NULL OR claimed_until <= $2)`
+	tag, err := r.db.Exec(ctx, query, id, now)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *PostgresScheduledMessageRepository) ClaimDue(ctx context.Context, now, claimedUntil time.Time, limit int) ([]*domain.ScheduledMessage, error) {
+	// SKIP LOCKED lets several nodes claim concurrently without handing out
+	// the same message twice.
+	query := `
+		UPDATE scheduled_messages SET claimed_until = $2
+		WHERE id IN (
+			SELECT id FROM scheduled_messages
+			WHERE send_at <= $1 AND (claimed_until IS NULL OR claimed_until <= $1)
+			ORDER BY send_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + scheduledMessageColumns
+	return r.query(ctx, query, now, claimedUntil, limit)
+}
+
+func (r *PostgresScheduledMessageRepository) Complete(ctx context.Context, id string) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM scheduled_messages WHERE id = $1`, id)
+	return err
+}
+
+func (r *PostgresScheduledMessageRepository) query(ctx context.Context, query string, args ...interface{}) ([]*domain.ScheduledMessage, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scheduled []*domain.ScheduledMessage
+	for rows.Next() {
+		sm, err := scanScheduledMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		scheduled = append(scheduled, sm)
+	}
+	return scheduled, rows.Err()
+}
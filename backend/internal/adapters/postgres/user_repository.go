@@ -6,11 +6,23 @@ import (
 	"fmt"
 	"real-time-chat/internal/domain"
 	"real-time-chat/internal/services" // For custom error types
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// userStatusColumns selects the custom status and do-not-disturb settings of
+// the users row aliased u, in the order userStatusFields scans them.
+const userStatusColumns = `u.status_text, u.status_emoji, u.status_expires_at, u.dnd_enabled, u.dnd_schedule`
+
+// userStatusFields attaches a fresh status to user and returns the scan
+// destinations for userStatusColumns. Call Status.Resolve after scanning.
+func userStatusFields(user *domain.User) []interface{} {
+	user.Status = &domain.UserStatus{}
+	return []interface{}{&user.Status.Text, &user.Status.Emoji, &user.Status.ExpiresAt, &user.Status.DoNotDisturb, &user.Status.DNDSchedule}
+}
+
 type PostgresUserRepository struct {
 	db *pgxpool.Pool
 }
@@ -44,10 +56,17 @@ func (r *PostgresUserRepository) UpdateVerificationStatus(ctx context.Context, u
 	return err
 }
 
-func (r *PostgresUserRepository) UpdateProfilePicture(ctx context.Context, userID, url string) error {
-	query := `UPDATE users SET profile_picture_url = $1 WHERE id = $2`
-	_, err := r.db.Exec(ctx, query, url, userID)
-	return err
+func (r *PostgresUserRepository) UpdateProfilePicture(ctx context.Context, userID, url string) (string, error) {
+	// Lock the row while reading the old URL so concurrent uploads each see
+	// the URL they actually replace.
+	query := `
+		UPDATE users u SET profile_picture_url = $1
+		FROM (SELECT id, profile_picture_url FROM users WHERE id = $2 FOR UPDATE) old
+		WHERE u.id = old.id
+		RETURNING COALESCE(old.profile_picture_url, '')`
+	var previousURL string
+	err := r.db.QueryRow(ctx, query, url, userID).Scan(&previousURL)
+	return previousURL, err
 }
 
 func (r *PostgresUserRepository) UpdatePassword(ctx context.Context, userID, newPasswordHash string) error {
@@ -56,17 +75,58 @@ func (r *PostgresUserRepository) UpdatePassword(ctx context.Context, userID, new
 	return err
 }
 
+func (r *PostgresUserRepository) UpdateLastSeen(ctx context.Context, userID string, at time.Time) error {
+	query := `UPDATE users SET last_seen_at = $1 WHERE id = $2`
+	_, err := r.db.Exec(ctx, query, at, userID)
+	return err
+}
+
+func (r *PostgresUserRepository) UpdatePresenceVisibility(ctx context.Context, userID string, hidden bool) error {
+	query := `UPDATE users SET hide_presence = $1 WHERE id = $2`
+	_, err := r.db.Exec(ctx, query, hidden, userID)
+	return err
+}
+
+func (r *PostgresUserRepository) UpdateStatus(ctx context.Context, userID string, status *domain.UserStatus) error {
+	query := `
+		UPDATE users SET status_text = $1, status_emoji = $2, status_expires_at = $3, dnd_enabled = $4, dnd_schedule = $5
+		WHERE id = $6`
+	_, err := r.db.Exec(ctx, query, status.Text, status.Emoji, status.ExpiresAt, status.DoNotDisturb, status.DNDSchedule, userID)
+	return err
+}
+
+func (r *PostgresUserRepository) FindPresence(ctx context.Context, userIDs []string) ([]*domain.Presence, error) {
+	query := `SELECT id, last_seen_at, hide_presence FROM users WHERE id = ANY($1::uuid[])`
+	rows, err := r.db.Query(ctx, query, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var presences []*domain.Presence
+	for rows.Next() {
+		presence := &domain.Presence{Status: domain.PresenceOffline}
+		if err := rows.Scan(&presence.UserID, &presence.LastSeenAt, &presence.Hidden); err != nil {
+			return nil, err
+		}
+		presences = append(presences, presence)
+	}
+	return presences, rows.Err()
+}
+
 func (r *PostgresUserRepository) findUserByField(ctx context.Context, field string, value interface{}) (*domain.User, error) {
 	user := &domain.User{}
-	query := fmt.Sprintf(`SELECT id, username, email, password_hash, profile_picture_url, is_verified, created_at FROM users WHERE %s = $1`, field)
-	err := r.db.QueryRow(ctx, query, value).Scan(
-		&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.ProfilePictureURL, &user.IsVerified, &user.CreatedAt,
-	)
+	query := fmt.Sprintf(`SELECT id, username, email, password_hash, profile_picture_url, is_verified, created_at, hide_presence, %s FROM users u WHERE %s = $1`, userStatusColumns, field)
+	dest := append([]interface{}{
+		&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.ProfilePictureURL, &user.IsVerified, &user.CreatedAt, &user.HidePresence,
+	}, userStatusFields(user)...)
+	err := r.db.QueryRow(ctx, query, value).Scan(dest...)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, services.ErrUserNotFound
 		}
 		return nil, err
 	}
+	user.Status.Resolve(time.Now())
 	return user, nil
 }
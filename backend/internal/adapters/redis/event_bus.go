@@ -0,0 +1,69 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"real-time-chat/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const userEventChannelPrefix = "events:user:"
+
+// RedisEventBus fans events out across nodes using one pub/sub channel per user.
+// A node only subscribes to the channels of users connected to it.
+type RedisEventBus struct {
+	client *redis.Client
+	pubsub *redis.PubSub
+}
+
+func NewRedisEventBus(client *redis.Client) domain.EventBus {
+	// Start with an empty subscription; channels are added as users connect.
+	return &RedisEventBus{client: client, pubsub: client.Subscribe(context.Background())}
+}
+
+func userEventChannel(userID string) string {
+	return userEventChannelPrefix + userID
+}
+
+func (b *RedisEventBus) Publish(ctx context.Context, event *domain.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, userEventChannel(event.UserID), data).Err()
+}
+
+func (b *RedisEventBus) SubscribeUser(ctx context.Context, userID string) error {
+	return b.pubsub.Subscribe(ctx, userEventChannel(userID))
+}
+
+func (b *RedisEventBus) UnsubscribeUser(ctx context.Context, userID string) error {
+	return b.pubsub.Unsubscribe(ctx, userEventChannel(userID))
+}
+
+// Listen delivers events received on subscribed channels to handler until ctx is done.
+func (b *RedisEventBus) Listen(ctx context.Context, handler func(event *domain.Event)) {
+	ch := b.pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var event domain.Event
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				log.Printf("error unmarshalling event from channel %s: %v", msg.Channel, err)
+				continue
+			}
+			handler(&event)
+		}
+	}
+}
+
+func (b *RedisEventBus) Close() error {
+	return b.pubsub.Close()
+}
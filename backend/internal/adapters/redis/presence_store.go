@@ -0,0 +1,162 @@
+package redis
+
+import (
+	"context"
+	"real-time-chat/internal/domain"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Sorted sets of a user's connections, scored by when each lapses (unix ms)
+	presenceOnlinePrefix = "presence:online:"
+	presenceAwayPrefix   = "presence:away:"
+	// Sorted set of users with connections, scored by when the last one lapses
+	presenceExpiryKey = "presence:expiry"
+)
+
+// presenceStatusLua drops lapsed connections and derives the user's status.
+// KEYS[1] and KEYS[2] are the online and away sets, ARGV[1] is now.
+const presenceStatusLua = `
+local function status(now)
+	redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+	redis.call('ZREMRANGEBYSCORE', KEYS[2], '-inf', now)
+	if redis.call('ZCARD', KEYS[1]) > 0 then
+		return 'online'
+	end
+	if redis.call('ZCARD', KEYS[2]) > 0 then
+		return 'away'
+	end
+	return 'offline'
+end
+`
+
+// ARGV: now, user ID, connection ID, status, expires at, TTL in ms
+var setConnectionScript = redis.NewScript(presenceStatusLua + `
+local before = status(ARGV[1])
+local target, other = KEYS[1], KEYS[2]
+if ARGV[4] == 'away' then
+	target, other = KEYS[2], KEYS[1]
+end
+redis.call('ZREM', other, ARGV[3])
+redis.call('ZADD', target, ARGV[5], ARGV[3])
+redis.call('PEXPIRE', KEYS[1], ARGV[6])
+redis.call('PEXPIRE', KEYS[2], ARGV[6])
+local latest = redis.call('ZSCORE', KEYS[3], ARGV[2])
+if not latest or tonumber(latest) < tonumber(ARGV[5]) then
+	redis.call('ZADD', KEYS[3], ARGV[5], ARGV[2])
+end
+return {before, status(ARGV[1])}
+`)
+
+// Only updates members that exist (XX), so a connection removed concurrently
+// stays removed. ARGV: now, user ID, connection ID, expires at, TTL in ms
+var refreshConnectionScript = redis.NewScript(`
+local changed = redis.call('ZADD', KEYS[1], 'XX', 'CH', ARGV[4], ARGV[3]) + redis.call('ZADD', KEYS[2], 'XX', 'CH', ARGV[4], ARGV[3])
+if changed == 0 then
+	return 0
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+redis.call('PEXPIRE', KEYS[2], ARGV[5])
+local latest = redis.call('ZSCORE', KEYS[3], ARGV[2])
+if not latest or tonumber(latest) < tonumber(ARGV[4]) then
+	redis.call('ZADD', KEYS[3], ARGV[4], ARGV[2])
+end
+return 1
+`)
+
+// ARGV: now, user ID, connection ID
+var removeConnectionScript = redis.NewScript(presenceStatusLua + `
+local before = status(ARGV[1])
+redis.call('ZREM', KEYS[1], ARGV[3])
+redis.call('ZREM', KEYS[2], ARGV[3])
+local after = status(ARGV[1])
+if after == 'offline' then
+	redis.call('ZREM', KEYS[3], ARGV[2])
+end
+return {before, after}
+`)
+
+// Pops users from the expiry index atomically, so only one replica gets each.
+// ARGV: now, limit
+var claimExpiredScript = redis.NewScript(`
+local users = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+if #users > 0 then
+	redis.call('ZREM', KEYS[1], unpack(users))
+end
+return users
+`)
+
+type RedisPresenceStore struct {
+	client *redis.Client
+}
+
+func NewRedisPresenceStore(client *redis.Client) domain.PresenceStore {
+	return &RedisPresenceStore{client: client}
+}
+
+func presenceKeys(userID string) []string {
+	return []string{presenceOnlinePrefix + userID, presenceAwayPrefix + userID, presenceExpiryKey}
+}
+
+func (s *RedisPresenceStore) SetConnection(ctx context.Context, userID, connectionID string, status domain.PresenceStatus, ttl time.Duration) (domain.PresenceStatus, domain.PresenceStatus, error) {
+	now := time.Now()
+	return runPresenceScript(ctx, s.client, setConnectionScript, presenceKeys(userID),
+		now.UnixMilli(), userID, connectionID, string(status), now.Add(ttl).UnixMilli(), ttl.Milliseconds())
+}
+
+func (s *RedisPresenceStore) RefreshConnection(ctx context.Context, userID, connectionID string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	return refreshConnectionScript.Run(ctx, s.client, presenceKeys(userID),
+		now.UnixMilli(), userID, connectionID, now.Add(ttl).UnixMilli(), ttl.Milliseconds()).Bool()
+}
+
+func (s *RedisPresenceStore) RemoveConnection(ctx context.Context, userID, connectionID string) (domain.PresenceStatus, domain.PresenceStatus, error) {
+	return runPresenceScript(ctx, s.client, removeConnectionScript, presenceKeys(userID),
+		time.Now().UnixMilli(), userID, connectionID)
+}
+
+func runPresenceScript(ctx context.Context, client *redis.Client, script *redis.Script, keys []string, args ...interface{}) (domain.PresenceStatus, domain.PresenceStatus, error) {
+	result, err := script.Run(ctx, client, keys, args...).StringSlice()
+	if err != nil {
+		return "", "", err
+	}
+	return domain.PresenceStatus(result[0]), domain.PresenceStatus(result[1]), nil
+}
+
+func (s *RedisPresenceStore) GetStatuses(ctx context.Context, userIDs []string) (map[string]domain.PresenceStatus, error) {
+	statuses := make(map[string]domain.PresenceStatus, len(userIDs))
+	if len(userIDs) == 0 {
+		return statuses, nil
+	}
+	// Lapsed connections are not cleaned up here, only excluded
+	notBefore := "(" + strconv.FormatInt(time.Now().UnixMilli(), 10)
+	pipe := s.client.Pipeline()
+	online := make([]*redis.IntCmd, len(userIDs))
+	away := make([]*redis.IntCmd, len(userIDs))
+	for i, userID := range userIDs {
+		online[i] = pipe.ZCount(ctx, presenceOnlinePrefix+userID, notBefore, "+inf")
+		away[i] = pipe.ZCount(ctx, presenceAwayPrefix+userID, notBefore, "+inf")
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	for i, userID := range userIDs {
+		switch {
+		case online[i].Val() > 0:
+			statuses[userID] = domain.PresenceOnline
+		case away[i].Val() > 0:
+			statuses[userID] = domain.PresenceAway
+		default:
+			statuses[userID] = domain.PresenceOffline
+		}
+	}
+	return statuses, nil
+}
+
+func (s *RedisPresenceStore) ClaimExpired(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	return claimExpiredScript.Run(ctx, s.client, []string{presenceExpiryKey}, now.UnixMilli(), limit).StringSlice()
+}
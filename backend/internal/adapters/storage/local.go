@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"real-time-chat/internal/domain"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalBlobStorage keeps blobs on the local filesystem. Signed URLs point at
+// baseURL, which must be routed to the store's ServeHTTP.
+type LocalBlobStorage struct {
+	root    string
+	baseURL string
+	secret  []byte
+}
+
+func NewLocalBlobStorage(root, baseURL, secret string) (*LocalBlobStorage, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	return &LocalBlobStorage{root: root, baseURL: strings.TrimSuffix(baseURL, "/"), secret: []byte(secret)}, nil
+}
+
+// path maps a key to a file under root; cleaning it as an absolute path
+// stops keys from escaping the root with "..".
+func (s *LocalBlobStorage) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(path.Clean("/"+key)))
+}
+
+func (s *LocalBlobStorage) Put(ctx context.Context, key, contentType string, content io.Reader, size int64) error {
+	dst := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial blob
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if _, err := io.Copy(tmp, content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+func (s *LocalBlobStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	file, err := os.Open(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, domain.ErrBlobNotFound
+	}
+	return file, err
+}
+
+func (s *LocalBlobStorage) Delete(ctx context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *LocalBlobStorage) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	if !domain.IsCleanBlobKey(key) {
+		return "", domain.ErrInvalidBlobKey
+	}
+	expires := time.Now().Add(expiry).Unix()
+	return fmt.Sprintf("%s/%s?expires=%d&signature=%s", s.baseURL, escapePath(key), expires, s.sign(key, expires)), nil
+}
+
+func (s *LocalBlobStorage) sign(key string, expires int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ServeHTTP serves a blob to holders of a valid, unexpired signed URL. The
+// request path (with baseURL stripped) is the key; SignedURL only signs clean
+// keys, so any other path is refused.
+func (s *LocalBlobStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil || !domain.IsCleanBlobKey(key) || time.Now().Unix() > expires ||
+		!hmac.Equal([]byte(s.sign(key, expires)), []byte(r.URL.Query().Get("signature"))) {
+		http.Error(w, "link is invalid or has expired", http.StatusForbidden)
+		return
+	}
+
+	file, err := os.Open(s.path(key))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Uploaded content is untrusted; never let it run as a page on our origin
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.FormatInt(max(expires-time.Now().Unix(), 0), 10))
+	http.ServeContent(w, r, path.Base(key), info.ModTime(), file)
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"real-time-chat/internal/domain"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLocalStorage(t *testing.T) *LocalBlobStorage {
+	t.Helper()
+	s, err := NewLocalBlobStorage(t.TempDir(), "/files", "secret")
+	if err != nil {
+		t.Fatalf("NewLocalBlobStorage: %v", err)
+	}
+	return s
+}
+
+func TestLocalSignedURLRefusesUncleanKeys(t *testing.T) {
+	s := newTestLocalStorage(t)
+	for _, key := range []string{"", ".", "..", "/avatars/a.png", "avatars/../attachments/a", "avatars//a.png", "avatars/./a.png", "avatars/"} {
+		if _, err := s.SignedURL(context.Background(), key, time.Minute); !errors.Is(err, domain.ErrInvalidBlobKey) {
+			t.Errorf("SignedURL(%q) returned %v, want ErrInvalidBlobKey", key, err)
+		}
+	}
+}
+
+func TestLocalServeHTTP(t *testing.T) {
+	s := newTestLocalStorage(t)
+	if err := os.MkdirAll(filepath.Join(s.root, "attachments"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(s.root, "attachments", "a.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	signed := func(key string) string {
+		expires := time.Now().Add(time.Minute).Unix()
+		return "/" + key + "?expires=" + strconv.FormatInt(expires, 10) + "&signature=" + s.sign(key, expires)
+	}
+	serve := func(target string) int {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec.Code
+	}
+
+	url, err := s.SignedURL(context.Background(), "attachments/a.txt", time.Minute)
+	if err != nil {
+		t.Fatalf("SignedURL: %v", err)
+	}
+	if code := serve(strings.TrimPrefix(url, "/files")); code != http.StatusOK {
+		t.Errorf("signed URL returned %d, want 200", code)
+	}
+	// Even a correctly signed unclean key must not resolve to another blob
+	if code := serve(signed("avatars/../attachments/a.txt")); code != http.StatusForbidden {
+		t.Errorf("unclean key returned %d, want 403", code)
+	}
+	if code := serve("/attachments/a.txt?expires=9999999999&signature=bad"); code != http.StatusForbidden {
+		t.Errorf("bad signature returned %d, want 403", code)
+	}
+}
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"real-time-chat/internal/domain"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	maxPresignExpiry = 7 * 24 * time.Hour // Upper bound S3 accepts for X-Amz-Expires
+)
+
+type S3Config struct {
+	Endpoint  string // e.g. https://s3.eu-west-1.amazonaws.com, or http://localhost:9000 for MinIO
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool // Address the bucket as /bucket/key instead of bucket.host/key; MinIO needs this
+}
+
+// S3BlobStorage talks to any S3-compatible object store over its REST API,
+// signing requests with AWS Signature Version 4.
+type S3BlobStorage struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+func NewS3BlobStorage(cfg S3Config) (*S3BlobStorage, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" || cfg.Region == "" {
+		return nil, fmt.Errorf("S3 bucket and region are required")
+	}
+	return &S3BlobStorage{cfg: cfg, endpoint: endpoint, client: &http.Client{Timeout: 2 * time.Minute}}, nil
+}
+
+func (s *S3BlobStorage) Put(ctx context.Context, key, contentType string, content io.Reader, size int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key).String(), content)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3BlobStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3BlobStorage) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key).String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// SignedURL builds a presigned GET URL, so clients download straight from the store.
+func (s *S3BlobStorage) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return s.presign(key, expiry, time.Now().UTC()), nil
+}
+
+func (s *S3BlobStorage) presign(key string, expiry time.Duration, now time.Time) string {
+	if expiry > maxPresignExpiry {
+		expiry = maxPresignExpiry
+	}
+	u := s.objectURL(key)
+
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", signingAlgorithm)
+	query.Set("X-Amz-Credential", s.cfg.AccessKey+"/"+s.scope(now))
+	query.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expiry.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		u.EscapedPath(),
+		canonicalQuery(query),
+		"host:" + u.Host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+	u.RawQuery = canonicalQuery(query) + "&X-Amz-Signature=" + s.signature(now, canonicalRequest)
+	return u.String()
+}
+
+// do signs and sends the request, turning non-2xx responses into errors.
+func (s *S3BlobStorage) do(req *http.Request) (*http.Response, error) {
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + unsignedPayload + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signingAlgorithm, s.cfg.AccessKey, s.scope(now), signedHeaders, s.signature(now, canonicalRequest)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, domain.ErrBlobNotFound
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, fmt.Errorf("s3 %s %s: %s: %s", req.Method, req.URL.Path, resp.Status, body)
+}
+
+func (s *S3BlobStorage) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	objectPath := "/" + strings.TrimPrefix(key, "/")
+	if s.cfg.PathStyle {
+		objectPath = "/" + s.cfg.Bucket + objectPath
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + objectPath
+	u.RawPath = escapePath(u.Path)
+	u.RawQuery = ""
+	return &u
+}
+
+func (s *S3BlobStorage) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.cfg.Region + "/s3/aws4_request"
+}
+
+func (s *S3BlobStorage) signature(t time.Time, canonicalRequest string) string {
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		signingAlgorithm,
+		t.Format("20060102T150405Z"),
+		s.scope(t),
+		hex.EncodeToString(hashed[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), t.Format("20060102"))
+	for _, part := range []string{s.cfg.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query parameters sorted by name, as SigV4 requires.
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		vs := append([]string(nil), values[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func escapePath(p string) string {
+	return uriEncode(p, false)
+}
+
+// uriEncode percent-encodes everything except RFC 3986 unreserved characters
+// (and "/" unless encodeSlash), matching what S3 expects when verifying signatures.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
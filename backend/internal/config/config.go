@@ -12,7 +12,23 @@ type Config struct {
 	RedisAddr   string `mapstructure:"REDIS_ADDR"`
 	RedisPass   string `mapstructure:"REDIS_PASSWORD"`
 	JWTSecret   string `mapstructure:"JWT_SECRET"`
-	UploadDir   string `mapstructure:"UPLOAD_DIR"` // For profile pictures
+	UploadDir   string `mapstructure:"UPLOAD_DIR"` // Root of the local blob store
+
+	// Blob storage for avatars and attachments: "local" (UploadDir) or "s3"
+	StorageBackend       string        `mapstructure:"STORAGE_BACKEND"`
+	StorageSigningSecret string        `mapstructure:"STORAGE_SIGNING_SECRET"` // Signs local download URLs; defaults to JWT_SECRET
+	SignedURLExpiry      time.Duration `mapstructure:"SIGNED_URL_EXPIRY"`
+	S3Endpoint           string        `mapstructure:"S3_ENDPOINT"`
+	S3Region             string        `mapstructure:"S3_REGION"`
+	S3Bucket             string        `mapstructure:"S3_BUCKET"`
+	S3AccessKey          string        `mapstructure:"S3_ACCESS_KEY"`
+	S3SecretKey          string        `mapstructure:"S3_SECRET_KEY"`
+	S3PathStyle          bool          `mapstructure:"S3_PATH_STYLE"`
+
+	MaxAttachmentSize int64 `mapstructure:"MAX_ATTACHMENT_SIZE"` // In bytes
+
+	// How long after sending a message its sender may still edit it
+	MessageEditWindow time.Duration `mapstructure:"MESSAGE_EDIT_WINDOW"`
 
 	// Email config for OTP/Verification
 	SMTPHost string `mapstructure:"SMTP_HOST"`
@@ -28,6 +44,11 @@ func LoadConfig(path string) (config Config, err error) {
 	viper.SetConfigType("env")
 	viper.SetDefault("SERVER_PORT", "8080")
 	viper.SetDefault("UPLOAD_DIR", "uploads")
+	viper.SetDefault("MESSAGE_EDIT_WINDOW", "15m")
+	viper.SetDefault("STORAGE_BACKEND", "local")
+	viper.SetDefault("SIGNED_URL_EXPIRY", "15m")
+	viper.SetDefault("S3_REGION", "us-east-1")
+	viper.SetDefault("MAX_ATTACHMENT_SIZE", 10*1024*1024)
 	viper.AutomaticEnv()
 
 	if err = viper.ReadInConfig(); err != nil {
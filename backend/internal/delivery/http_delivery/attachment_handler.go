@@ -0,0 +1,105 @@
+package http_delivery
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"real-time-chat/internal/domain"
+	"real-time-chat/internal/services"
+	"real-time-chat/internal/usecase"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type AttachmentHandler struct {
+	attachmentService usecase.AttachmentUseCase
+	maxSize           int64
+}
+
+func NewAttachmentHandler(as usecase.AttachmentUseCase, maxSize int64) *AttachmentHandler {
+	return &AttachmentHandler{attachmentService: as, maxSize: maxSize}
+}
+
+// UploadAttachment stores a file for the conversation; its ID is then passed
+// in attachment_ids when sending the message.
+func (h *AttachmentHandler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	conversationID := chi.URLParam(r, "conversationID")
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxSize+1024*1024) // Leave room for multipart framing
+	if err := r.ParseMultipartForm(1024 * 1024); err != nil {    // Larger files spill to disk
+		ErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("File too large or invalid form: %v", err))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Error retrieving the file")
+		return
+	}
+	defer file.Close()
+
+	attachment, err := h.attachmentService.Upload(r.Context(), conversationID, user.ID, header.Filename, file)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusCreated, attachment)
+}
+
+func (h *AttachmentHandler) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	attachmentID := chi.URLParam(r, "attachmentID")
+
+	attachment, content, err := h.attachmentService.Open(r.Context(), attachmentID, user.ID)
+	if err != nil {
+		attachmentErrorResponse(w, err)
+		return
+	}
+	defer content.Close()
+
+	// Only images are shown inline; everything else is downloaded
+	disposition := "attachment"
+	if strings.HasPrefix(attachment.MimeType, "image/") {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Type", attachment.MimeType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}))
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.SizeBytes, 10))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	if _, err := io.Copy(w, content); err != nil {
+		log.Printf("Error streaming attachment %s: %v", attachment.ID, err)
+	}
+}
+
+// GetDownloadURL returns a signed, expiring URL for fetching the attachment
+// straight from storage, e.g. for <img> tags that can't send auth headers.
+func (h *AttachmentHandler) GetDownloadURL(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	attachmentID := chi.URLParam(r, "attachmentID")
+
+	url, expiresAt, err := h.attachmentService.SignedURL(r.Context(), attachmentID, user.ID)
+	if err != nil {
+		attachmentErrorResponse(w, err)
+		return
+	}
+	JSONResponse(w, http.StatusOK, map[string]interface{}{"url": url, "expires_at": expiresAt})
+}
+
+func attachmentErrorResponse(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrAttachmentNotFound):
+		ErrorResponse(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrNotParticipant):
+		ErrorResponse(w, http.StatusForbidden, err.Error())
+	default:
+		ErrorResponse(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -1,11 +1,11 @@
 package http_delivery
 
 import (
+	"encoding/json"
 	"net/http"
 	"real-time-chat/internal/domain"
 	"real-time-chat/internal/usecase"
 	"strconv"
-	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -33,17 +33,10 @@ func (h *ConversationHandler) GetMessages(w http.ResponseWriter, r *http.Request
 	user := r.Context().Value(userContextKey).(*domain.User)
 	conversationID := chi.URLParam(r, "conversationID")
 
-	cursorStr := r.URL.Query().Get("before")
-	var before time.Time
-	if cursorStr != "" {
-		ts, err := time.Parse(time.RFC3339Nano, cursorStr)
-		if err != nil {
-			ErrorResponse(w, http.StatusBadRequest, "Invalid 'before' timestamp format")
-			return
-		}
-		before = ts
-	} else {
-		before = time.Now().UTC()
+	before, after, err := messageCursorParams(r)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
 	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
@@ -51,7 +44,7 @@ func (h *ConversationHandler) GetMessages(w http.ResponseWriter, r *http.Request
 		limit = 20
 	}
 
-	messages, err := h.messageService.GetMessagesForConversation(r.Context(), conversationID, user.ID, before, limit)
+	messages, err := h.messageService.GetMessagesForConversation(r.Context(), conversationID, user.ID, before, after, limit)
 	if err != nil {
 		ErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
@@ -59,6 +52,40 @@ func (h *ConversationHandler) GetMessages(w http.ResponseWriter, r *http.Request
 	JSONResponse(w, http.StatusOK, messages)
 }
 
+// GetPinnedMessages lists the conversation's pins, most recently pinned first.
+func (h *ConversationHandler) GetPinnedMessages(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	conversationID := chi.URLParam(r, "conversationID")
+
+	pins, err := h.messageService.GetPinnedMessages(r.Context(), conversationID, user.ID)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, pins)
+}
+
+// SetMessageTTL turns disappearing messages on ({"ttl_seconds": 86400}) or off
+// ({"ttl_seconds": 0}) and returns the system message announcing it.
+func (h *ConversationHandler) SetMessageTTL(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	conversationID := chi.URLParam(r, "conversationID")
+	var req struct {
+		TTLSeconds int `json:"ttl_seconds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	notice, err := h.messageService.SetMessageTTL(r.Context(), conversationID, user.ID, req.TTLSeconds)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, notice)
+}
+
 func (h *ConversationHandler) MarkAsRead(w http.ResponseWriter, r *http.Request) {
 	user := r.Context().Value(userContextKey).(*domain.User)
 	conversationID := chi.URLParam(r, "conversationID")
@@ -19,7 +19,7 @@ func NewLongPollingHandler(es usecase.EventUseCase, us usecase.UserUseCase) *Lon
 	return &LongPollingHandler{eventService: es, userService: us}
 }
 
-// HandleLongPolling retrieves events for a user after a given event ID.
+// HandleLongPolling retrieves events for a user after a given sequence number.
 func (h *LongPollingHandler) HandleLongPolling(w http.ResponseWriter, r *http.Request) {
 	// Authenticate the user for long polling, similar to middleware
 	authHeader := r.Header.Get("Authorization")
@@ -47,8 +47,15 @@ func (h *LongPollingHandler) HandleLongPolling(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// Get query parameters
-	sinceEventID := r.URL.Query().Get("since")
+	// Get query parameters; "since" is the seq of the last event the client has seen
+	var sinceSeq int64
+	if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+		sinceSeq, err = strconv.ParseInt(sinceStr, 10, 64)
+		if err != nil {
+			ErrorResponse(w, http.StatusBadRequest, "Invalid 'since' sequence number")
+			return
+		}
+	}
 	limitStr := r.URL.Query().Get("limit")
 	limit, _ := strconv.Atoi(limitStr)
 	if limit == 0 {
@@ -63,28 +70,12 @@ func (h *LongPollingHandler) HandleLongPolling(w http.ResponseWriter, r *http.Re
 	ctx, cancel := context.WithTimeout(r.Context(), 59*time.Second) // Long polling timeout (less than client's 60s)
 	defer cancel()
 
-	// Loop to poll for new events
-	for {
-		events, err := h.eventService.GetEventsForUser(ctx, user.ID, sinceEventID, limit)
-		if err != nil {
-			ErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve events")
-			return
-		}
-
-		if len(events) > 0 {
-			// If events found, send them immediately
-			JSONResponse(w, http.StatusOK, events)
-			return
-		}
-
-		select {
-		case <-ctx.Done():
-			// Timeout or client disconnected, send empty array
-			JSONResponse(w, http.StatusOK, []interface{}{})
-			return
-		case <-time.After(1 * time.Second): // Polling interval on server side
-			// Continue to next iteration after a short delay
-			// This prevents constant database hammering if no events are present.
-		}
+	// Blocks until an event for the user is stored or the timeout passes,
+	// in which case an empty array is returned
+	events, err := h.eventService.WaitForEvents(ctx, user.ID, sinceSeq, limit)
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve events")
+		return
 	}
+	JSONResponse(w, http.StatusOK, events)
 }
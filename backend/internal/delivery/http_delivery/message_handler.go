@@ -0,0 +1,314 @@
+package http_delivery
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"real-time-chat/internal/domain"
+	"real-time-chat/internal/usecase"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type MessageHandler struct {
+	messageService usecase.MessageUseCase
+}
+
+func NewMessageHandler(ms usecase.MessageUseCase) *MessageHandler {
+	return &MessageHandler{messageService: ms}
+}
+
+type EditMessageRequest struct {
+	Content string `json:"content"`
+}
+
+func (h *MessageHandler) EditMessage(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	messageID := chi.URLParam(r, "messageID")
+
+	var req EditMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	message, err := h.messageService.EditMessage(r.Context(), messageID, user.ID, req.Content)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, message)
+}
+
+func (h *MessageHandler) GetRevisions(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	messageID := chi.URLParam(r, "messageID")
+
+	revisions, err := h.messageService.GetMessageRevisions(r.Context(), messageID, user.ID)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, revisions)
+}
+
+func (h *MessageHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	messageID := chi.URLParam(r, "messageID")
+
+	scope := domain.MessageDeleteScope(r.URL.Query().Get("scope"))
+	if scope == "" {
+		scope = domain.DeleteForMe
+	}
+
+	if err := h.messageService.DeleteMessage(r.Context(), messageID, user.ID, scope); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, map[string]string{"message": "Message deleted"})
+}
+
+func (h *MessageHandler) GetThread(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	messageID := chi.URLParam(r, "messageID")
+
+	before, after, err := messageCursorParams(r)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	replies, err := h.messageService.GetThreadReplies(r.Context(), messageID, user.ID, before, after, limit)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, replies)
+}
+
+// GetMessageContext returns the message with ?limit= messages (default 25) on
+// either side, for jumping to a message that may not be loaded yet.
+func (h *MessageHandler) GetMessageContext(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	messageID := chi.URLParam(r, "messageID")
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	window, err := h.messageService.GetMessagesAround(r.Context(), messageID, user.ID, limit)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, window)
+}
+
+// GetReceipts returns per-recipient delivered/read status and the seen-by list.
+func (h *MessageHandler) GetReceipts(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	messageID := chi.URLParam(r, "messageID")
+
+	receipts, err := h.messageService.GetMessageReceipts(r.Context(), messageID, user.ID)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, receipts)
+}
+
+// messageCursorParams reads the before/after history cursors, taken from a
+// message's cursor field. A bare RFC 3339 timestamp is still accepted for
+// before, meaning every message sent earlier than it.
+func messageCursorParams(r *http.Request) (before, after *domain.MessageCursor, err error) {
+	if token := r.URL.Query().Get("before"); token != "" {
+		if before, err = domain.ParseMessageCursor(token); err != nil {
+			ts, tsErr := time.Parse(time.RFC3339Nano, token)
+			if tsErr != nil {
+				return nil, nil, err
+			}
+			before, err = &domain.MessageCursor{Timestamp: ts, ID: uuid.Nil.String()}, nil
+		}
+	}
+	if token := r.URL.Query().Get("after"); token != "" {
+		if after, err = domain.ParseMessageCursor(token); err != nil {
+			return nil, nil, err
+		}
+	}
+	return before, after, nil
+}
+
+type ReactionRequest struct {
+	Emoji string `json:"emoji"`
+}
+
+func (h *MessageHandler) AddReaction(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	messageID := chi.URLParam(r, "messageID")
+
+	var req ReactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.messageService.AddReaction(r.Context(), messageID, user.ID, req.Emoji); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, map[string]string{"message": "Reaction added"})
+}
+
+func (h *MessageHandler) RemoveReaction(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	messageID := chi.URLParam(r, "messageID")
+
+	if err := h.messageService.RemoveReaction(r.Context(), messageID, user.ID, r.URL.Query().Get("emoji")); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, map[string]string{"message": "Reaction removed"})
+}
+
+// PinMessage pins the message to the top of its conversation. Either side of
+// a 1:1 chat may pin; in a group only the owner may, since groups have no
+// admin role yet.
+func (h *MessageHandler) PinMessage(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	messageID := chi.URLParam(r, "messageID")
+
+	pin, err := h.messageService.PinMessage(r.Context(), messageID, user.ID)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, pin)
+}
+
+// UnpinMessage unpins the message; the same users as for PinMessage may do so.
+func (h *MessageHandler) UnpinMessage(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	messageID := chi.URLParam(r, "messageID")
+
+	if err := h.messageService.UnpinMessage(r.Context(), messageID, user.ID); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, map[string]string{"message": "Message unpinned"})
+}
+
+// ForwardMessages copies {"message_ids": [...]} into each of {"conversation_ids": [...]}.
+func (h *MessageHandler) ForwardMessages(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	var req struct {
+		MessageIDs      []string `json:"message_ids"`
+		ConversationIDs []string `json:"conversation_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	forwarded, err := h.messageService.ForwardMessages(r.Context(), user.ID, req.MessageIDs, req.ConversationIDs)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusCreated, forwarded)
+}
+
+// BookmarkMessage saves the message to the user's bookmarks with an optional
+// private note. An empty body bookmarks it without one.
+func (h *MessageHandler) BookmarkMessage(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	messageID := chi.URLParam(r, "messageID")
+	var req struct {
+		Note string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	bookmark, err := h.messageService.BookmarkMessage(r.Context(), messageID, user.ID, req.Note)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, bookmark)
+}
+
+func (h *MessageHandler) RemoveBookmark(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	messageID := chi.URLParam(r, "messageID")
+
+	if err := h.messageService.RemoveBookmark(r.Context(), messageID, user.ID); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, map[string]string{"message": "Bookmark removed"})
+}
+
+// GetBookmarks handles GET /bookmarks?cursor=&limit=, newest bookmarks first.
+func (h *MessageHandler) GetBookmarks(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	var cursor *domain.MessageCursor
+	if token := r.URL.Query().Get("cursor"); token != "" {
+		c, err := domain.ParseMessageCursor(token)
+		if err != nil {
+			ErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		cursor = c
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	page, err := h.messageService.GetBookmarks(r.Context(), user.ID, cursor, limit)
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, page)
+}
+
+// SearchMessages handles GET /messages/search?q=...&conversation_id=&sender_id=&from=&to=&cursor=&limit=
+// where from and to are RFC 3339 timestamps.
+func (h *MessageHandler) SearchMessages(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	query := r.URL.Query()
+
+	filter := domain.MessageSearchFilter{
+		Query:          query.Get("q"),
+		ConversationID: query.Get("conversation_id"),
+		SenderID:       query.Get("sender_id"),
+	}
+	for param, dst := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(param); value != "" {
+			ts, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				ErrorResponse(w, http.StatusBadRequest, "Invalid '"+param+"' timestamp format")
+				return
+			}
+			*dst = ts
+		}
+	}
+	var cursor *domain.MessageCursor
+	if token := query.Get("cursor"); token != "" {
+		c, err := domain.ParseMessageCursor(token)
+		if err != nil {
+			ErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		cursor = c
+	}
+	limit, _ := strconv.Atoi(query.Get("limit"))
+
+	page, err := h.messageService.SearchMessages(r.Context(), user.ID, filter, cursor, limit)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, page)
+}
@@ -0,0 +1,45 @@
+package http_delivery
+
+import (
+	"encoding/json"
+	"net/http"
+	"real-time-chat/internal/domain"
+	"real-time-chat/internal/usecase"
+)
+
+type PresenceHandler struct {
+	service usecase.PresenceUseCase
+}
+
+func NewPresenceHandler(service usecase.PresenceUseCase) *PresenceHandler {
+	return &PresenceHandler{service: service}
+}
+
+// GetFriendsPresence returns the online status and last-seen time of each friend.
+func (h *PresenceHandler) GetFriendsPresence(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	presences, err := h.service.GetFriendsPresence(r.Context(), user.ID)
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, presences)
+}
+
+// UpdatePresenceVisibility lets the user hide their presence from friends.
+func (h *PresenceHandler) UpdatePresenceVisibility(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	var req struct {
+		Hidden bool `json:"hidden"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.service.SetPresenceHidden(r.Context(), user.ID, req.Hidden); err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, map[string]bool{"hidden": req.Hidden})
+}
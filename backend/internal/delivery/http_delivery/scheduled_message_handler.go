@@ -0,0 +1,82 @@
+package http_delivery
+
+import (
+	"encoding/json"
+	"net/http"
+	"real-time-chat/internal/domain"
+	"real-time-chat/internal/usecase"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type ScheduledMessageHandler struct {
+	service usecase.ScheduledMessageUseCase
+}
+
+func NewScheduledMessageHandler(service usecase.ScheduledMessageUseCase) *ScheduledMessageHandler {
+	return &ScheduledMessageHandler{service: service}
+}
+
+type scheduledMessageRequest struct {
+	Content string    `json:"content"`
+	SendAt  time.Time `json:"send_at"` // RFC 3339
+}
+
+// ScheduleMessage queues a message to be sent into the conversation at send_at.
+func (h *ScheduledMessageHandler) ScheduleMessage(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	var req scheduledMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	scheduled, err := h.service.ScheduleMessage(r.Context(), &domain.ScheduledMessage{
+		ConversationID: chi.URLParam(r, "conversationID"),
+		SenderID:       user.ID,
+		Content:        req.Content,
+		SendAt:         req.SendAt,
+	})
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusCreated, scheduled)
+}
+
+// GetScheduledMessages lists the user's pending scheduled messages, soonest first.
+func (h *ScheduledMessageHandler) GetScheduledMessages(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	scheduled, err := h.service.GetScheduledMessages(r.Context(), user.ID)
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, scheduled)
+}
+
+func (h *ScheduledMessageHandler) UpdateScheduledMessage(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	var req scheduledMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	scheduled, err := h.service.UpdateScheduledMessage(r.Context(), chi.URLParam(r, "scheduledID"), user.ID, req.Content, req.SendAt)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, scheduled)
+}
+
+func (h *ScheduledMessageHandler) CancelScheduledMessage(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	if err := h.service.CancelScheduledMessage(r.Context(), chi.URLParam(r, "scheduledID"), user.ID); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, map[string]string{"message": "Scheduled message cancelled"})
+}
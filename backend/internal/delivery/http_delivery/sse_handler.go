@@ -0,0 +1,92 @@
+package http_delivery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"real-time-chat/internal/domain"
+	"real-time-chat/internal/usecase"
+	"strconv"
+	"time"
+)
+
+const (
+	// Comment frames sent while idle keep proxies from closing the stream
+	sseKeepAliveInterval = 25 * time.Second
+	sseBatchSize         = 100
+)
+
+type SSEHandler struct {
+	eventService usecase.EventUseCase
+}
+
+func NewSSEHandler(es usecase.EventUseCase) *SSEHandler {
+	return &SSEHandler{eventService: es}
+}
+
+// HandleEventStream streams the user's events as text/event-stream. Each event
+// carries its seq as the SSE id, so a reconnecting client resumes from the
+// Last-Event-ID header. Without one, the stream starts from now.
+func (h *SSEHandler) HandleEventStream(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		ErrorResponse(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	var sinceSeq int64
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		seq, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			ErrorResponse(w, http.StatusBadRequest, "Invalid Last-Event-ID")
+			return
+		}
+		sinceSeq = seq
+	} else {
+		latest, err := h.eventService.GetEventsForUser(r.Context(), user.ID, 0, 1)
+		if err != nil {
+			ErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve events")
+			return
+		}
+		if len(latest) > 0 {
+			sinceSeq = latest[0].Seq
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable proxy buffering (nginx)
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		waitCtx, cancel := context.WithTimeout(r.Context(), sseKeepAliveInterval)
+		events, err := h.eventService.WaitForEvents(waitCtx, user.ID, sinceSeq, sseBatchSize)
+		cancel()
+		if r.Context().Err() != nil {
+			return // Client went away
+		}
+		if err != nil {
+			log.Printf("error streaming events to user %s: %v", user.ID, err)
+			return
+		}
+
+		if len(events) == 0 {
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		for _, event := range events {
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\ndata: %s\n\n", event.Seq, data)
+			sinceSeq = event.Seq
+		}
+		flusher.Flush()
+	}
+}
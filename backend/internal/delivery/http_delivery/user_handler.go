@@ -2,28 +2,25 @@ package http_delivery
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
-	"path/filepath"
 	"real-time-chat/internal/domain"
+	"real-time-chat/internal/services"
 	"real-time-chat/internal/usecase"
-	"strings"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/google/uuid"
 )
 
 type UserHandler struct {
 	userService  usecase.UserUseCase
 	tokenService usecase.TokenUseCase
 	jwtSecret    string
-	uploadDir    string
 }
 
-func NewUserHandler(us usecase.UserUseCase, ts usecase.TokenUseCase, jwtSecret, uploadDir string) *UserHandler {
-	return &UserHandler{userService: us, tokenService: ts, jwtSecret: jwtSecret, uploadDir: uploadDir}
+func NewUserHandler(us usecase.UserUseCase, ts usecase.TokenUseCase, jwtSecret string) *UserHandler {
+	return &UserHandler{userService: us, tokenService: ts, jwtSecret: jwtSecret}
 }
 
 type RegisterRequest struct {
@@ -99,6 +96,28 @@ func (h *UserHandler) GetMe(w http.ResponseWriter, r *http.Request) {
 	JSONResponse(w, http.StatusOK, user)
 }
 
+// UpdateStatus replaces the user's custom status and do-not-disturb setting.
+// Send empty text and emoji to clear the status.
+func (h *UserHandler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextKey).(*domain.User)
+	var status domain.UserStatus
+	if err := json.NewDecoder(r.Body).Decode(&status); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	updated, err := h.userService.UpdateStatus(r.Context(), user.ID, &status)
+	if errors.Is(err, services.ErrStatusTooLong) || errors.Is(err, services.ErrStatusExpiryInPast) || errors.Is(err, domain.ErrInvalidDNDSchedule) {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	JSONResponse(w, http.StatusOK, updated)
+}
+
 type VerifyEmailRequest struct {
 	Token string `json:"token"`
 }
@@ -166,33 +185,19 @@ func (h *UserHandler) UploadProfilePicture(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	file, handler, err := r.FormFile("profile_picture")
+	file, _, err := r.FormFile("profile_picture")
 	if err != nil {
 		ErrorResponse(w, http.StatusBadRequest, "Error retrieving the file")
 		return
 	}
 	defer file.Close()
 
-	// Generate a unique filename
-	extension := filepath.Ext(handler.Filename)
-	filename := uuid.NewString() + extension
-	filePath := filepath.Join(h.uploadDir, filename)
-
-	dst, err := os.Create(filePath)
-	if err != nil {
-		ErrorResponse(w, http.StatusInternalServerError, "Error creating file on server")
-		return
-	}
-	defer dst.Close()
-
-	if _, err := io.Copy(dst, file); err != nil {
-		ErrorResponse(w, http.StatusInternalServerError, "Error saving file on server")
+	profilePictureURL, err := h.userService.UploadProfilePicture(r.Context(), user.ID, file)
+	if errors.Is(err, services.ErrProfilePictureFormat) || errors.Is(err, services.ErrProfilePictureTooBig) {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
-
-	profilePictureURL, err := h.userService.UploadProfilePicture(r.Context(), user.ID, filename)
 	if err != nil {
-		os.Remove(filePath) // Clean up uploaded file if DB update fails
 		ErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -200,19 +205,16 @@ func (h *UserHandler) UploadProfilePicture(w http.ResponseWriter, r *http.Reques
 	JSONResponse(w, http.StatusOK, map[string]string{"profile_picture_url": profilePictureURL})
 }
 
-// FileServer serves static files from a http.FileSystem.
-func FileServer(r chi.Router, path string, root http.FileSystem) {
-	if strings.ContainsAny(path, "{}*") {
-		panic("FileServer does not permit URL parameters.")
-	}
-
-	fs := http.StripPrefix(path, http.FileServer(root))
-
-	if path != "/" && path[len(path)-1] != '/' {
-		r.Get(path, http.RedirectHandler(path+"/", http.StatusMovedPermanently).ServeHTTP)
-		path += "/"
+// ServeProfilePicture redirects to a short-lived signed URL for a profile
+// picture, so the stored /uploads/... links keep working on any storage backend.
+// An optional ?size=32|64|256 picks a smaller thumbnail.
+func (h *UserHandler) ServeProfilePicture(w http.ResponseWriter, r *http.Request) {
+	size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+	url, err := h.userService.ProfilePictureURL(r.Context(), chi.URLParam(r, "*"), size)
+	if err != nil {
+		ErrorResponse(w, http.StatusNotFound, err.Error())
+		return
 	}
-	r.Get(path+"*", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		fs.ServeHTTP(w, r)
-	}))
+	w.Header().Set("Cache-Control", "public, max-age=300") // Well within the signed URL's lifetime
+	http.Redirect(w, r, url, http.StatusFound)
 }
@@ -2,10 +2,12 @@ package ws_delivery
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
 	"real-time-chat/internal/domain" // Import domain to use EventType
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -17,6 +19,8 @@ const (
 	// Ping period is 30 seconds as per requirement (must be less than pongWait)
 	pingPeriod     = 30 * time.Second
 	maxMessageSize = 512
+	// Events fetched per page when replaying missed events on reconnect
+	replayPageSize = 100
 )
 
 var (
@@ -37,6 +41,130 @@ type Client struct {
 	conn   *websocket.Conn
 	send   chan []byte
 	UserID string
+	ID     string // Identifies this connection among the user's devices/tabs
+
+	mu        sync.Mutex
+	closed    bool
+	replaying bool     // Live messages are held back while missed events are replayed
+	held      [][]byte // Live messages received during replay
+	lastSeq   int64    // Seq of the last stored event queued; later ones must follow it in order
+
+	typingSent      map[string]time.Time  // Last typing_start passed to the hub, per conversation; owned by readPump
+	heartbeatStatus domain.PresenceStatus // Last heartbeat passed to the hub; owned by readPump
+	heartbeatAt     time.Time
+}
+
+// queue hands a message to the write pump. It returns false if the client
+// cannot keep up and should be dropped.
+func (c *Client) queue(message []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.queueLocked(message)
+}
+
+// queueEvent hands a stored event to the write pump unless one with the same
+// or a later seq was already queued. Callers fill gaps first; see gapBefore.
+func (c *Client) queueEvent(seq int64, message []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.replaying {
+		if seq <= c.lastSeq {
+			return true
+		}
+		c.lastSeq = seq
+	}
+	return c.queueLocked(message)
+}
+
+// gapBefore reports whether stored events between the last one queued and seq
+// are missing, and if so the seq they follow.
+func (c *Client) gapBefore(seq int64) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.replaying || seq <= c.lastSeq+1 {
+		return 0, false
+	}
+	return c.lastSeq, true
+}
+
+func (c *Client) queueLocked(message []byte) bool {
+	if c.closed {
+		return true
+	}
+	if c.replaying {
+		if len(c.held) >= cap(c.send) {
+			return false
+		}
+		c.held = append(c.held, message)
+		return true
+	}
+	select {
+	case c.send <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+// close closes the send channel exactly once, which stops the write pump.
+func (c *Client) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.send)
+	}
+}
+
+// replay writes every event stored after lastSeq straight to the
+// connection, then releases live messages held back in the meantime,
+// skipping any that were already replayed. It must run before writePump
+// is started.
+func (c *Client) replay() {
+	sinceSeq := c.lastSeq
+pages:
+	for {
+		events, err := c.hub.eventService.GetEventsForUser(context.Background(), c.UserID, sinceSeq, replayPageSize)
+		if err != nil {
+			log.Printf("error replaying events for user %s since seq %d: %v", c.UserID, sinceSeq, err)
+			break
+		}
+		for _, event := range events {
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				log.Printf("error writing replayed event to user %s: %v", c.UserID, err)
+				break pages
+			}
+			sinceSeq = event.Seq
+		}
+		if len(events) < replayPageSize {
+			break
+		}
+	}
+
+	c.mu.Lock()
+	held := c.held
+	c.held = nil
+	c.replaying = false
+	c.lastSeq = sinceSeq
+	c.mu.Unlock()
+
+	// Held events may have arrived out of order; queueing them like live ones
+	// skips those already replayed and fills any gap
+	for _, message := range held {
+		var event struct {
+			Seq int64 `json:"seq"`
+		}
+		json.Unmarshal(message, &event)
+		if !c.hub.queueMessage(c, event.Seq, message) {
+			c.close()
+			return
+		}
+	}
 }
 
 func (c *Client) readPump() {
@@ -62,12 +190,23 @@ func (c *Client) readPump() {
 			payload := &BroadcastPayload{
 				Message:      message,
 				SenderID:     c.UserID,
+				client:       c,
 				EventType:    domain.EventType(msg.Type), // Convert string type to domain.EventType
 				EventPayload: msg.Payload,                // The raw JSON payload for event persistence
 			}
-			// Extract ConversationID if it's a message type
-			if msg.Type == "send_message" {
+			// Extract ConversationID if it's a message or typing frame
+			switch msg.Type {
+			case "send_message":
+				payload.ConversationID = msg.GetConversationID()
+			case "typing_start", "typing_stop":
 				payload.ConversationID = msg.GetConversationID()
+				if !c.allowTyping(msg.Type, payload.ConversationID, time.Now()) {
+					continue
+				}
+			case "heartbeat":
+				if !c.allowHeartbeat(msg.Payload, time.Now()) {
+					continue
+				}
 			}
 			// Extract RecipientID if it's a game invite
 			if msg.Type == "game_invite" {
@@ -0,0 +1,22 @@
+package ws_delivery
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// How often each node purges disappearing messages that have expired
+const messageExpiryInterval = 30 * time.Second
+
+// runMessageExpiry deletes expired disappearing messages. Nodes purging at
+// the same time never delete, and so announce, the same message twice.
+func (h *Hub) runMessageExpiry() {
+	ticker := time.NewTicker(messageExpiryInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := h.messageService.ExpireMessages(context.Background()); err != nil {
+			log.Printf("error expiring messages: %v", err)
+		}
+	}
+}
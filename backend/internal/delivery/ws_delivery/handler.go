@@ -1,9 +1,13 @@
 package ws_delivery
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"real-time-chat/internal/usecase"
+	"strconv"
+
+	"github.com/google/uuid"
 )
 
 type WSHandler struct {
@@ -33,9 +37,36 @@ func (h *WSHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	client := &Client{hub: h.hub, conn: conn, send: make(chan []byte, 256), UserID: claims.UserID}
+	// Clients reconnecting after a drop pass the sequence number of the last
+	// event they saw, so anything broadcast in the gap is replayed before live
+	// delivery resumes.
+	lastSeq, _ := strconv.ParseInt(r.URL.Query().Get("last_seq"), 10, 64)
+	replaying := lastSeq > 0
+	if !replaying {
+		// Live events are delivered in order from the user's latest one on
+		lastSeq = h.latestSeq(r.Context(), claims.UserID)
+	}
+
+	client := &Client{hub: h.hub, conn: conn, send: make(chan []byte, 256), UserID: claims.UserID, ID: uuid.NewString(), replaying: replaying, lastSeq: lastSeq}
 	client.hub.register <- client
+	if replaying {
+		client.replay()
+	}
 
 	go client.writePump()
 	go client.readPump()
 }
+
+// latestSeq returns the seq of the user's most recent event, or 0 if there is
+// none or it can't be read.
+func (h *WSHandler) latestSeq(ctx context.Context, userID string) int64 {
+	events, err := h.hub.eventService.GetEventsForUser(ctx, userID, 0, 1)
+	if err != nil {
+		log.Printf("error reading latest event for user %s: %v", userID, err)
+		return 0
+	}
+	if len(events) == 0 {
+		return 0
+	}
+	return events[0].Seq
+}
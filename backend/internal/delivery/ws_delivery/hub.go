@@ -7,20 +7,25 @@ import (
 	"real-time-chat/internal/domain"
 	"real-time-chat/internal/usecase"
 	"sync"
+	"time"
 )
 
 // Hub maintains the set of active clients and broadcasts messages to the
 // clients.
 type Hub struct {
-	clients        map[string]*Client // Map userID to Client
-	mu             sync.RWMutex
-	broadcast      chan *BroadcastPayload
-	register       chan *Client
-	unregister     chan *Client
-	messageService usecase.MessageUseCase
-	convoService   usecase.ConversationUseCase
-	gameService    usecase.GameUseCase
-	eventService   usecase.EventUseCase // Added EventService
+	clients         map[string]map[*Client]bool // Map userID to that user's live connections (one per device/tab)
+	mu              sync.RWMutex
+	broadcast       chan *BroadcastPayload
+	register        chan *Client
+	unregister      chan *Client
+	messageService  usecase.MessageUseCase
+	convoService    usecase.ConversationUseCase
+	gameService     usecase.GameUseCase
+	eventService    usecase.EventUseCase            // Added EventService
+	presenceService usecase.PresenceUseCase         // Online/away/offline per connection, shared across replicas; optional
+	scheduled       usecase.ScheduledMessageUseCase // Due messages go out through sendMessage; optional
+	bus             domain.EventBus                 // Cross-node fan-out; nil when running a single node
+	typing          map[typingKey]*typingState      // Live typing indicators started on this node; owned by Run
 }
 
 type BroadcastPayload struct {
@@ -30,37 +35,76 @@ type BroadcastPayload struct {
 	RecipientID    string           // For targeted messages like game invites, friend requests
 	EventType      domain.EventType // What type of event this is (e.g., "new_message", "game_invite", "game_update")
 	EventPayload   interface{}      // The raw payload for the event service
+	client         *Client          // Connection the frame arrived on
 }
 
-func NewHub(messageService usecase.MessageUseCase, convoService usecase.ConversationUseCase, gameService usecase.GameUseCase, eventService usecase.EventUseCase) *Hub {
-	return &Hub{
-		broadcast:      make(chan *BroadcastPayload),
-		register:       make(chan *Client),
-		unregister:     make(chan *Client),
-		clients:        make(map[string]*Client),
-		messageService: messageService,
-		convoService:   convoService,
-		gameService:    gameService,
-		eventService:   eventService,
+// NewHub creates a hub. presenceService and scheduled may be nil, which turns
+// off presence tracking and sending scheduled messages; bus may be nil on a
+// single node.
+func NewHub(messageService usecase.MessageUseCase, convoService usecase.ConversationUseCase, gameService usecase.GameUseCase, eventService usecase.EventUseCase, presenceService usecase.PresenceUseCase, scheduled usecase.ScheduledMessageUseCase, bus domain.EventBus) *Hub {
+	h := &Hub{
+		broadcast:       make(chan *BroadcastPayload),
+		register:        make(chan *Client),
+		unregister:      make(chan *Client),
+		clients:         make(map[string]map[*Client]bool),
+		typing:          make(map[typingKey]*typingState),
+		messageService:  messageService,
+		convoService:    convoService,
+		gameService:     gameService,
+		eventService:    eventService,
+		presenceService: presenceService,
+		scheduled:       scheduled,
+		bus:             bus,
 	}
+	eventService.AddListener(h.pushEvent)
+	return h
 }
 
 func (h *Hub) Run() {
+	if h.bus != nil {
+		go h.bus.Listen(context.Background(), h.deliverEvent)
+	}
+	if h.scheduled != nil {
+		go h.runScheduler()
+	}
+	go h.runMessageExpiry()
+
+	typingSweep := time.NewTicker(typingSweepInterval)
+	defer typingSweep.Stop()
+	presenceRefresh := time.NewTicker(presenceRefreshInterval)
+	defer presenceRefresh.Stop()
+
 	for {
 		select {
+		case now := <-typingSweep.C:
+			h.expireTyping(now)
+		case <-presenceRefresh.C:
+			h.refreshPresence()
 		case client := <-h.register:
 			h.mu.Lock()
-			h.clients[client.UserID] = client
+			conns, ok := h.clients[client.UserID]
+			if !ok {
+				conns = make(map[*Client]bool)
+				h.clients[client.UserID] = conns
+			}
+			conns[client] = true
 			h.mu.Unlock()
-			log.Printf("Client connected: %s", client.UserID)
+			if !ok {
+				h.subscribeUser(client.UserID)
+			}
+			h.reportPresence(client, domain.PresenceOnline)
+			log.Printf("Client connected: %s (%d active connections)", client.UserID, len(conns))
 		case client := <-h.unregister:
 			h.mu.Lock()
-			if _, ok := h.clients[client.UserID]; ok {
-				delete(h.clients, client.UserID)
-				close(client.send)
-				log.Printf("Client disconnected: %s", client.UserID)
-			}
+			gone := h.removeClientLocked(client)
 			h.mu.Unlock()
+			if gone {
+				h.unsubscribeUser(client.UserID)
+				h.stopTypingForUser(client.UserID)
+			}
+			// The connection may already have been dropped from h.clients
+			// on an earlier unregister, so presence is updated regardless of gone
+			h.reportDisconnect(client)
 		case payload := <-h.broadcast:
 			var msg WebSocketMessage
 			if err := json.Unmarshal(payload.Message, &msg); err != nil {
@@ -68,46 +112,6 @@ func (h *Hub) Run() {
 				continue
 			}
 
-			// Persist the event to the database for long polling / history
-			// The eventService will determine who the event is for based on type and payload.
-			// This assumes `payload.EventPayload` is correctly set in client.go/handler.
-			if payload.EventType != "" {
-				userIDsForEvent := []string{}
-				switch payload.EventType {
-				case domain.EventNewMessage:
-					// For new messages, the event is for all participants
-					participantIDs, err := h.convoService.GetParticipantIDs(context.Background(), payload.ConversationID)
-					if err != nil {
-						log.Printf("error getting participants for message event: %v", err)
-					} else {
-						userIDsForEvent = participantIDs
-					}
-				case domain.EventFriendRequest:
-					// For friend request, event is for the recipient
-					if payload.RecipientID != "" {
-						userIDsForEvent = []string{payload.RecipientID}
-					}
-				case domain.EventGameInvite:
-					// For game invite, event is for the invited player
-					if payload.RecipientID != "" {
-						userIDsForEvent = []string{payload.RecipientID}
-					}
-				// Other events (game updates, group changes, etc.) should also be handled
-				default:
-					// If no specific recipient logic, assume event is for the sender (or the user who triggered it)
-					if payload.SenderID != "" {
-						userIDsForEvent = []string{payload.SenderID}
-					}
-				}
-
-				for _, userID := range userIDsForEvent {
-					if err := h.eventService.CreateEvent(context.Background(), userID, payload.EventType, payload.EventPayload); err != nil {
-						log.Printf("Failed to create event for user %s, type %s: %v", userID, payload.EventType, err)
-					}
-				}
-			}
-
-			// Then broadcast via WebSocket to active clients
 			switch msg.Type {
 			case "send_message":
 				domainMsg, err := msg.ToDomainMessage(payload.SenderID)
@@ -116,50 +120,200 @@ func (h *Hub) Run() {
 					continue
 				}
 
-				savedMsg, err := h.messageService.SaveMessage(context.Background(), domainMsg)
-				if err != nil {
+				if _, err := h.sendMessage(context.Background(), domainMsg); err != nil {
 					log.Printf("Error saving message: %v", err)
-					continue
 				}
 
-				// Re-marshal with full sender info for clients
-				wsMsgWithSender := WebSocketMessage{Type: msg.Type, Payload: nil}
-				wsMsgWithSender.Payload, _ = json.Marshal(savedMsg) // Use the saved message with sender details
-				finalPayload, _ := json.Marshal(wsMsgWithSender)
-
-				participantIDs, err := h.convoService.GetParticipantIDs(context.Background(), domainMsg.ConversationID)
-				if err != nil {
-					log.Printf("error getting participants for convo %s: %v", domainMsg.ConversationID, err)
+			case "message_delivered":
+				var p MessageDeliveredPayload
+				if err := json.Unmarshal(msg.Payload, &p); err != nil {
+					log.Printf("error unmarshalling delivery ack: %v", err)
 					continue
 				}
+				if err := h.messageService.MarkMessageDelivered(context.Background(), p.MessageID, payload.SenderID); err != nil {
+					log.Printf("Error marking message %s delivered to %s: %v", p.MessageID, payload.SenderID, err)
+				}
+
+			case "heartbeat":
+				h.heartbeat(payload.client, msg.Payload)
 
-				h.broadcastToUsersWS(participantIDs, finalPayload)
+			case "typing_start":
+				h.startTyping(payload.SenderID, payload.ConversationID)
 
-			case "game_invite", "game_update":
-				// These are already handled by eventService and will be picked up by WS clients if active
-				// No direct WS broadcast needed here as eventService does the work
-				log.Printf("Game event type %s, hub does not directly broadcast this via WS anymore, relying on eventService", msg.Type)
+			case "typing_stop":
+				h.stopTyping(payload.SenderID, payload.ConversationID)
 
 			default:
-				log.Printf("Unknown WebSocket message type: %s", msg.Type)
+				h.persistClientEvent(payload)
 			}
 		}
 	}
 }
 
-// Helper to broadcast to a list of user IDs via WebSocket only
-func (h *Hub) broadcastToUsersWS(userIDs []string, message []byte) {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+// sendMessage saves a message and tells every participant about it. An error
+// means the message wasn't saved.
+func (h *Hub) sendMessage(ctx context.Context, message *domain.Message) (*domain.Message, error) {
+	savedMsg, err := h.messageService.SaveMessage(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+
+	participantIDs, err := h.convoService.GetParticipantIDs(ctx, message.ConversationID)
+	if err != nil {
+		log.Printf("error getting participants for convo %s: %v", message.ConversationID, err)
+		return savedMsg, nil
+	}
+
+	// Persist a new_message event (with full sender info) for every participant.
+	// The event listener pushes it to their live connections.
+	h.createEvents(participantIDs, domain.EventNewMessage, savedMsg)
+	return savedMsg, nil
+}
+
+// persistClientEvent stores a client-originated frame as an event for the
+// user(s) it concerns. Delivery to live connections happens via pushEvent.
+func (h *Hub) persistClientEvent(payload *BroadcastPayload) {
+	if payload.EventType == "" {
+		return
+	}
+
+	userIDsForEvent := []string{}
+	switch payload.EventType {
+	case domain.EventFriendRequest:
+		// For friend request, event is for the recipient
+		if payload.RecipientID != "" {
+			userIDsForEvent = []string{payload.RecipientID}
+		}
+	case domain.EventGameInvite:
+		// For game invite, event is for the invited player
+		if payload.RecipientID != "" {
+			userIDsForEvent = []string{payload.RecipientID}
+		}
+	default:
+		// If no specific recipient logic, assume event is for the sender (or the user who triggered it)
+		if payload.SenderID != "" {
+			userIDsForEvent = []string{payload.SenderID}
+		}
+	}
+
+	h.createEvents(userIDsForEvent, payload.EventType, payload.EventPayload)
+}
+
+func (h *Hub) createEvents(userIDs []string, eventType domain.EventType, eventPayload interface{}) {
 	for _, userID := range userIDs {
-		if client, ok := h.clients[userID]; ok {
-			select {
-			case client.send <- message:
-				// Sent via WebSocket
-			default:
-				close(client.send)
-				delete(h.clients, userID) // Remove problematic client
+		if err := h.eventService.CreateEvent(context.Background(), userID, eventType, eventPayload); err != nil {
+			log.Printf("Failed to create event for user %s, type %s: %v", userID, eventType, err)
+		}
+	}
+}
+
+// pushEvent is registered as an EventListener so every persisted event,
+// whichever service created it, reaches the recipient's open connections.
+// With an event bus the event goes through it, so nodes holding the
+// recipient's connections (including this one) deliver it.
+func (h *Hub) pushEvent(event *domain.Event) {
+	if h.bus != nil {
+		err := h.bus.Publish(context.Background(), event)
+		if err == nil {
+			return
+		}
+		log.Printf("error publishing event %s, delivering locally only: %v", event.ID, err)
+	}
+	h.deliverEvent(event)
+}
+
+// deliverEvent sends an event to the recipient's connections on this node.
+func (h *Hub) deliverEvent(event *domain.Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("error marshalling event %s: %v", event.ID, err)
+		return
+	}
+
+	h.mu.RLock()
+	clients := make([]*Client, 0, len(h.clients[event.UserID]))
+	for client := range h.clients[event.UserID] {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+	for _, client := range clients {
+		if !h.queueMessage(client, event.Seq, data) {
+			client.close() // Its read pump then unregisters it
+		}
+	}
+}
+
+// queueMessage queues an event on one connection. Stored events go out in seq
+// order: they can arrive out of order when created concurrently or on other
+// nodes, so any missing in between are read back from the store first and
+// the client's resume cursor never skips one. Ephemeral events (seq 0) are
+// queued as they come. It returns false if the connection should be dropped.
+func (h *Hub) queueMessage(client *Client, seq int64, message []byte) bool {
+	if seq == 0 {
+		return client.queue(message)
+	}
+	if since, gap := client.gapBefore(seq); gap {
+		if seq-since-1 > replayPageSize {
+			return false // Too far behind; the client replays the rest on reconnect
+		}
+		missing, err := h.eventService.GetEventsForUser(context.Background(), client.UserID, since, int(seq-since-1))
+		if err != nil {
+			log.Printf("error reading events %d-%d for user %s: %v", since+1, seq-1, client.UserID, err)
+			return false
+		}
+		for _, event := range missing {
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			if !client.queueEvent(event.Seq, data) {
+				return false
 			}
 		}
 	}
+	return client.queueEvent(seq, message)
+}
+
+func (h *Hub) subscribeUser(userID string) {
+	if h.bus == nil {
+		return
+	}
+	if err := h.bus.SubscribeUser(context.Background(), userID); err != nil {
+		log.Printf("error subscribing to events for user %s: %v", userID, err)
+	}
+}
+
+func (h *Hub) unsubscribeUser(userID string) {
+	if h.bus == nil {
+		return
+	}
+	if err := h.bus.UnsubscribeUser(context.Background(), userID); err != nil {
+		log.Printf("error unsubscribing from events for user %s: %v", userID, err)
+	}
+}
+
+// IsUserOnline reports whether the user has at least one live connection.
+func (h *Hub) IsUserOnline(userID string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients[userID]) > 0
+}
+
+// removeClientLocked drops a single connection and closes its send channel.
+// The user is only considered gone once their last connection is removed,
+// which is reported by the return value. The caller must hold h.mu for writing.
+func (h *Hub) removeClientLocked(client *Client) bool {
+	conns, ok := h.clients[client.UserID]
+	if !ok || !conns[client] {
+		return false
+	}
+	delete(conns, client)
+	client.close()
+	if len(conns) == 0 {
+		delete(h.clients, client.UserID)
+		log.Printf("Client disconnected: %s (no connections left)", client.UserID)
+		return true
+	}
+	log.Printf("Client connection closed: %s (%d active connections)", client.UserID, len(conns))
+	return false
 }
@@ -0,0 +1,197 @@
+package ws_delivery
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"real-time-chat/internal/domain"
+	"real-time-chat/internal/usecase"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	redisadapter "real-time-chat/internal/adapters/redis"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeEventService keeps the listeners a hub registers and serves stored
+// events from memory.
+type fakeEventService struct {
+	listeners []usecase.EventListener
+	stored    []*domain.Event // In seq order
+}
+
+func (f *fakeEventService) CreateEvent(ctx context.Context, userID string, eventType domain.EventType, payload interface{}) error {
+	return nil
+}
+
+func (f *fakeEventService) GetEventsForUser(ctx context.Context, userID string, sinceSeq int64, limit int) ([]*domain.Event, error) {
+	var events []*domain.Event
+	for _, event := range f.stored {
+		if event.UserID == userID && event.Seq > sinceSeq && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (f *fakeEventService) WaitForEvents(ctx context.Context, userID string, sinceSeq int64, limit int) ([]*domain.Event, error) {
+	return nil, nil
+}
+
+func (f *fakeEventService) AddListener(listener usecase.EventListener) {
+	f.listeners = append(f.listeners, listener)
+}
+
+// newTestRedis connects to REDIS_ADDR (default localhost:6379), skipping the
+// test when no Redis is reachable.
+func newTestRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr, Password: os.Getenv("REDIS_PASSWORD")})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		t.Skipf("Redis not available at %s: %v", addr, err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// newTestHub starts a hub with its own bus connection, like a separate node.
+func newTestHub(t *testing.T, rdb *redis.Client) *Hub {
+	t.Helper()
+	bus := redisadapter.NewRedisEventBus(rdb)
+	t.Cleanup(func() { bus.Close() })
+	h := NewHub(nil, nil, nil, &fakeEventService{}, nil, nil, bus)
+	go h.Run()
+	return h
+}
+
+// connect registers a connection without a socket; its send channel stands
+// in for the write pump.
+func connect(h *Hub, userID string, buffer int) *Client {
+	client := &Client{hub: h, send: make(chan []byte, buffer), UserID: userID, ID: uuid.NewString()}
+	h.register <- client
+	return client
+}
+
+var testSeq atomic.Int64
+
+func newTestEvent(userID string) *domain.Event {
+	return newTestEventWithSeq(userID, testSeq.Add(1))
+}
+
+func newTestEventWithSeq(userID string, seq int64) *domain.Event {
+	return &domain.Event{
+		ID:              uuid.NewString(),
+		UserID:          userID,
+		Seq:             seq,
+		EventType:       domain.EventNewMessage,
+		Payload:         json.RawMessage(`{}`),
+		ServerTimestamp: time.Now().UTC(),
+	}
+}
+
+// expectEvent publishes event from hub until client receives it. The
+// subscription is made on the other hub's Run goroutine, so early publishes
+// may be missed.
+func expectEvent(t *testing.T, from *Hub, client *Client, event *domain.Event) {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	retry := time.NewTicker(50 * time.Millisecond)
+	defer retry.Stop()
+
+	from.pushEvent(event)
+	for {
+		select {
+		case message, ok := <-client.send:
+			if !ok {
+				t.Fatalf("connection of user %s was closed", client.UserID)
+			}
+			var got domain.Event
+			if err := json.Unmarshal(message, &got); err != nil {
+				t.Fatalf("unmarshalling delivered event: %v", err)
+			}
+			if got.ID == event.ID {
+				return
+			}
+		case <-retry.C:
+			from.pushEvent(event)
+		case <-deadline:
+			t.Fatalf("event %s never reached user %s", event.ID, client.UserID)
+		}
+	}
+}
+
+func TestEventReachesUserOnOtherHub(t *testing.T) {
+	rdb := newTestRedis(t)
+	hubA, hubB := newTestHub(t, rdb), newTestHub(t, rdb)
+
+	userID := uuid.NewString()
+	client := connect(hubB, userID, 16)
+	expectEvent(t, hubA, client, newTestEvent(userID))
+}
+
+func TestReconnectAfterSlowClientIsDroppedStaysSubscribed(t *testing.T) {
+	rdb := newTestRedis(t)
+	hubA, hubB := newTestHub(t, rdb), newTestHub(t, rdb)
+
+	userID := uuid.NewString()
+	slow := connect(hubB, userID, 1)
+	expectEvent(t, hubA, slow, newTestEvent(userID))
+
+	// Fill the buffer so the next delivery drops the connection
+	slow.send <- []byte(`{}`)
+	hubB.deliverEvent(newTestEvent(userID))
+	for range slow.send {
+	}
+
+	// The read pump unregisters a dropped connection; the user reconnects at once
+	hubB.unregister <- slow
+	client := connect(hubB, userID, 16)
+	expectEvent(t, hubA, client, newTestEvent(userID))
+}
+
+func TestEventsAreQueuedInSeqOrder(t *testing.T) {
+	userID := uuid.NewString()
+	events := &fakeEventService{}
+	for seq := int64(1); seq <= 4; seq++ {
+		events.stored = append(events.stored, newTestEventWithSeq(userID, seq))
+	}
+	h := NewHub(nil, nil, nil, events, nil, nil, nil)
+	client := &Client{hub: h, send: make(chan []byte, 16), UserID: userID, ID: uuid.NewString(), lastSeq: 1}
+	h.clients[userID] = map[*Client]bool{client: true}
+
+	// 4 overtakes 2 and 3, which are read back from the store; the late
+	// copies are then dropped
+	for _, seq := range []int64{4, 2, 3, 4} {
+		h.deliverEvent(events.stored[seq-1])
+	}
+	// Ephemeral events don't take part in the ordering
+	h.deliverEvent(newTestEventWithSeq(userID, 0))
+
+	var got []int64
+	for len(client.send) > 0 {
+		var event domain.Event
+		if err := json.Unmarshal(<-client.send, &event); err != nil {
+			t.Fatalf("unmarshalling delivered event: %v", err)
+		}
+		got = append(got, event.Seq)
+	}
+	want := []int64{2, 3, 4, 0}
+	if len(got) != len(want) {
+		t.Fatalf("delivered seqs %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("delivered seqs %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package ws_delivery
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"real-time-chat/internal/domain"
+	"time"
+)
+
+const (
+	// How often the hub refreshes presence of its connections and looks for
+	// users left behind by crashed nodes. Must stay well below the presence TTL.
+	presenceRefreshInterval = 30 * time.Second
+	// Heartbeats repeating the same status faster than this are dropped
+	heartbeatMinInterval = 10 * time.Second
+)
+
+// allowHeartbeat reports whether a heartbeat frame should be passed on to the
+// hub: status changes always are, repeats only after heartbeatMinInterval.
+// It is only called from readPump.
+func (c *Client) allowHeartbeat(payload json.RawMessage, now time.Time) bool {
+	var p HeartbeatPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return false
+	}
+	if p.Status == c.heartbeatStatus && now.Sub(c.heartbeatAt) < heartbeatMinInterval {
+		return false
+	}
+	c.heartbeatStatus = p.Status
+	c.heartbeatAt = now
+	return true
+}
+
+// heartbeat records the status a connection reported for its user.
+func (h *Hub) heartbeat(client *Client, payload json.RawMessage) {
+	var p HeartbeatPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		log.Printf("error unmarshalling heartbeat: %v", err)
+		return
+	}
+	h.reportPresence(client, p.Status)
+}
+
+// reportPresence records a connection's status, if presence is tracked.
+func (h *Hub) reportPresence(client *Client, status domain.PresenceStatus) {
+	if h.presenceService == nil {
+		return
+	}
+	if err := h.presenceService.Heartbeat(context.Background(), client.UserID, client.ID, status); err != nil {
+		log.Printf("error updating presence of user %s: %v", client.UserID, err)
+	}
+}
+
+// reportDisconnect records that a connection went away, if presence is tracked.
+func (h *Hub) reportDisconnect(client *Client) {
+	if h.presenceService == nil {
+		return
+	}
+	if err := h.presenceService.Disconnect(context.Background(), client.UserID, client.ID); err != nil {
+		log.Printf("error updating presence of user %s: %v", client.UserID, err)
+	}
+}
+
+// refreshPresence keeps the presence of every connection on this node from
+// lapsing, then expires users whose node went away without disconnecting
+// them. The Redis round trips run off the Run goroutine; KeepAlive can't
+// revive a connection that disconnects meanwhile.
+func (h *Hub) refreshPresence() {
+	if h.presenceService == nil {
+		return
+	}
+	var connections []*Client
+	h.mu.RLock()
+	for _, conns := range h.clients {
+		for client := range conns {
+			connections = append(connections, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	go func() {
+		ctx := context.Background()
+		for _, c := range connections {
+			if err := h.presenceService.KeepAlive(ctx, c.UserID, c.ID); err != nil {
+				log.Printf("error refreshing presence of user %s: %v", c.UserID, err)
+			}
+		}
+		if err := h.presenceService.ExpireStale(ctx); err != nil {
+			log.Printf("error expiring stale presence: %v", err)
+		}
+	}()
+}
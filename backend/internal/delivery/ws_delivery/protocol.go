@@ -12,8 +12,39 @@ type WebSocketMessage struct {
 }
 
 type SendMessagePayload struct {
+	ConversationID string   `json:"conversation_id"`
+	Content        string   `json:"content"`
+	ReplyToID      string   `json:"reply_to_id,omitempty"`    // Quote this message inline
+	ThreadRootID   string   `json:"thread_root_id,omitempty"` // Post into this message's sub-thread
+	AttachmentIDs  []string `json:"attachment_ids,omitempty"` // Uploaded beforehand via the attachments endpoint
+}
+
+// MessageDeliveredPayload acknowledges that a device received a message (and
+// everything before it in that conversation).
+type MessageDeliveredPayload struct {
+	MessageID string `json:"message_id"`
+}
+
+// HeartbeatPayload reports whether the user is active on a connection
+// ("online") or has left it idle ("away"). Clients send one whenever that
+// changes.
+type HeartbeatPayload struct {
+	Status domain.PresenceStatus `json:"status"`
+}
+
+// TypingPayload is sent with typing_start (repeated while the user keeps
+// typing) and typing_stop frames.
+type TypingPayload struct {
+	ConversationID string `json:"conversation_id"`
+}
+
+// TypingUpdate is relayed to the other participants of a conversation as the
+// payload of typing_started/typing_stopped events. Clients should drop the
+// indicator after ExpiresInMs even if no typing_stopped arrives.
+type TypingUpdate struct {
 	ConversationID string `json:"conversation_id"`
-	Content        string `json:"content"`
+	UserID         string `json:"user_id"`
+	ExpiresInMs    int64  `json:"expires_in_ms,omitempty"`
 }
 
 func (wsm *WebSocketMessage) ToDomainMessage(senderID string) (*domain.Message, error) {
@@ -24,11 +55,21 @@ func (wsm *WebSocketMessage) ToDomainMessage(senderID string) (*domain.Message,
 	if err := json.Unmarshal(wsm.Payload, &p); err != nil {
 		return nil, err
 	}
-	return &domain.Message{
+	msg := &domain.Message{
 		ConversationID: p.ConversationID,
 		SenderID:       senderID,
 		Content:        p.Content,
-	}, nil
+	}
+	if p.ReplyToID != "" {
+		msg.ReplyToID = &p.ReplyToID
+	}
+	if p.ThreadRootID != "" {
+		msg.ThreadRootID = &p.ThreadRootID
+	}
+	for _, id := range p.AttachmentIDs {
+		msg.Attachments = append(msg.Attachments, &domain.Attachment{ID: id})
+	}
+	return msg, nil
 }
 
 func (wsm *WebSocketMessage) GetConversationID() string {
@@ -39,6 +80,13 @@ func (wsm *WebSocketMessage) GetConversationID() string {
 		}
 		return p.ConversationID
 	}
+	if wsm.Type == "typing_start" || wsm.Type == "typing_stop" {
+		var p TypingPayload
+		if err := json.Unmarshal(wsm.Payload, &p); err != nil {
+			return ""
+		}
+		return p.ConversationID
+	}
 	// Other message types might not have a conversation ID directly in their payload or need different parsing
 	return ""
 }
@@ -0,0 +1,47 @@
+package ws_delivery
+
+import (
+	"context"
+	"log"
+	"real-time-chat/internal/domain"
+	"time"
+)
+
+// How often each node looks for scheduled messages that have fallen due
+const scheduledSendInterval = 5 * time.Second
+
+// runScheduler sends scheduled messages once due, the same way as messages
+// sent live. Every node runs one; claims keep them from sending a message twice.
+func (h *Hub) runScheduler() {
+	ticker := time.NewTicker(scheduledSendInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		h.sendScheduled(context.Background())
+	}
+}
+
+// sendScheduled sends everything due, batch by batch. Messages that fail for
+// a passing reason stay claimed for a while, so they don't come straight back.
+func (h *Hub) sendScheduled(ctx context.Context) {
+	for {
+		due, err := h.scheduled.ClaimDue(ctx)
+		if err != nil {
+			log.Printf("error claiming scheduled messages: %v", err)
+			return
+		}
+		if len(due) == 0 {
+			return
+		}
+		for _, scheduled := range due {
+			// SaveMessage checks the sender still belongs to the conversation
+			sent, err := h.sendMessage(ctx, &domain.Message{
+				ConversationID: scheduled.ConversationID,
+				SenderID:       scheduled.SenderID,
+				Content:        scheduled.Content,
+			})
+			if err := h.scheduled.CompleteSend(ctx, scheduled, sent, err); err != nil {
+				log.Printf("error completing scheduled message %s: %v", scheduled.ID, err)
+			}
+		}
+	}
+}
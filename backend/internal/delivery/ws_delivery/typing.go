@@ -0,0 +1,160 @@
+package ws_delivery
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"real-time-chat/internal/domain"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// A typing indicator is cleared if no typing_start refresh arrives
+	// within this window, so a crashed client can't leave it stuck.
+	typingTimeout = 8 * time.Second
+	// How often the hub looks for expired typing indicators
+	typingSweepInterval = time.Second
+	// Minimum gap between typing_start frames a connection may pass to the
+	// hub for the same conversation; extra frames are dropped.
+	typingThrottle = 3 * time.Second
+	// Conversations a single connection may be typing in at once
+	maxTypingConversations = 5
+)
+
+type typingKey struct {
+	conversationID string
+	userID         string
+}
+
+// typingState tracks one user typing in one conversation. It is only touched
+// from the hub's Run goroutine.
+type typingState struct {
+	recipients []string // The other participants, resolved when typing starts
+	expiresAt  time.Time
+}
+
+// allowTyping reports whether a typing frame should be passed on to the hub.
+// typing_start is throttled per conversation and the number of conversations
+// a connection can be typing in is capped; typing_stop only goes through
+// after a start did. It is only called from readPump.
+func (c *Client) allowTyping(msgType, conversationID string, now time.Time) bool {
+	if conversationID == "" {
+		return false
+	}
+	if c.typingSent == nil {
+		c.typingSent = make(map[string]time.Time)
+	}
+	last, active := c.typingSent[conversationID]
+	if msgType == "typing_stop" {
+		delete(c.typingSent, conversationID)
+		return active
+	}
+	if active && now.Sub(last) < typingThrottle {
+		return false
+	}
+	if !active {
+		// Entries past the timeout have already been expired by the hub
+		for id, sent := range c.typingSent {
+			if now.Sub(sent) >= typingTimeout {
+				delete(c.typingSent, id)
+			}
+		}
+		if len(c.typingSent) >= maxTypingConversations {
+			return false
+		}
+	}
+	c.typingSent[conversationID] = now
+	return true
+}
+
+// startTyping marks the user as typing in the conversation (or refreshes the
+// indicator) and tells the other participants.
+func (h *Hub) startTyping(userID, conversationID string) {
+	key := typingKey{conversationID: conversationID, userID: userID}
+	state, ok := h.typing[key]
+	if !ok {
+		participantIDs, err := h.convoService.GetParticipantIDs(context.Background(), conversationID)
+		if err != nil {
+			log.Printf("error getting participants for convo %s: %v", conversationID, err)
+			return
+		}
+		isParticipant := false
+		var recipients []string
+		for _, id := range participantIDs {
+			if id == userID {
+				isParticipant = true
+			} else {
+				recipients = append(recipients, id)
+			}
+		}
+		if !isParticipant {
+			return
+		}
+		state = &typingState{recipients: recipients}
+		h.typing[key] = state
+	}
+	state.expiresAt = time.Now().Add(typingTimeout)
+	h.relayTyping(state.recipients, domain.EventTypingStarted, TypingUpdate{
+		ConversationID: conversationID,
+		UserID:         userID,
+		ExpiresInMs:    typingTimeout.Milliseconds(),
+	})
+}
+
+// stopTyping clears the user's typing indicator in the conversation, if any.
+func (h *Hub) stopTyping(userID, conversationID string) {
+	key := typingKey{conversationID: conversationID, userID: userID}
+	if state, ok := h.typing[key]; ok {
+		h.clearTyping(key, state)
+	}
+}
+
+// stopTypingForUser clears every typing indicator of a user whose last
+// connection went away.
+func (h *Hub) stopTypingForUser(userID string) {
+	for key, state := range h.typing {
+		if key.userID == userID {
+			h.clearTyping(key, state)
+		}
+	}
+}
+
+// expireTyping clears indicators that have not been refreshed in time.
+func (h *Hub) expireTyping(now time.Time) {
+	for key, state := range h.typing {
+		if now.After(state.expiresAt) {
+			h.clearTyping(key, state)
+		}
+	}
+}
+
+func (h *Hub) clearTyping(key typingKey, state *typingState) {
+	delete(h.typing, key)
+	h.relayTyping(state.recipients, domain.EventTypingStopped, TypingUpdate{
+		ConversationID: key.conversationID,
+		UserID:         key.userID,
+	})
+}
+
+// relayTyping pushes a typing update to live connections without going
+// through eventService. The events carry no seq, so they are never replayed
+// and don't move a client's resume cursor.
+func (h *Hub) relayTyping(userIDs []string, eventType domain.EventType, update TypingUpdate) {
+	payload, err := json.Marshal(update)
+	if err != nil {
+		log.Printf("error marshalling typing update: %v", err)
+		return
+	}
+	now := time.Now()
+	for _, userID := range userIDs {
+		h.pushEvent(&domain.Event{
+			ID:              uuid.New().String(),
+			UserID:          userID,
+			EventType:       eventType,
+			Payload:         payload,
+			ServerTimestamp: now,
+		})
+	}
+}
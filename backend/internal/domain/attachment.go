@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrAttachmentAlreadySent = errors.New("some attachments were already sent with another message")
+
+type Attachment struct {
+	ID             string    `json:"id"`
+	ConversationID string    `json:"conversation_id"`
+	UploaderID     string    `json:"uploader_id"`
+	MessageID      *string   `json:"message_id,omitempty"` // Nil until sent with a message
+	FileName       string    `json:"file_name"`
+	MimeType       string    `json:"mime_type"`
+	SizeBytes      int64     `json:"size_bytes"`
+	Width          *int      `json:"width,omitempty"`  // Images only
+	Height         *int      `json:"height,omitempty"` // Images only
+	Checksum       string    `json:"checksum"`         // Hex SHA-256 of the content
+	StorageKey     string    `json:"-"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type AttachmentRepository interface {
+	Create(ctx context.Context, attachment *Attachment) error
+	// FindByID does not return attachments of messages deleted for everyone
+	FindByID(ctx context.Context, attachmentID string) (*Attachment, error)
+	// GetForMessages returns attachments keyed by message ID, leaving out those
+	// of messages deleted for everyone
+	GetForMessages(ctx context.Context, messageIDs []string) (map[string][]*Attachment, error)
+	// DeleteForMessages removes the messages' attachments and returns the
+	// storage keys no remaining attachment (e.g. a forwarded copy) uses
+	DeleteForMessages(ctx context.Context, messageIDs []string) ([]string, error)
+}
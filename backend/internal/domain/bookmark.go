@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Bookmark is a message a user saved for later, visible only to them.
+type Bookmark struct {
+	Message   *Message  `json:"message"`
+	Note      string    `json:"note,omitempty"` // Private to the user who saved it
+	CreatedAt time.Time `json:"created_at"`
+	Cursor    string    `json:"cursor"` // Pass as cursor to page past this bookmark
+}
+
+type BookmarkPage struct {
+	Bookmarks  []*Bookmark `json:"bookmarks"`
+	NextCursor string      `json:"next_cursor,omitempty"` // Empty on the last page
+}
+
+type BookmarkRepository interface {
+	// Save bookmarks bookmark.Message for userID, replacing the note of an
+	// existing bookmark. CreatedAt is set to when it was first saved.
+	Save(ctx context.Context, userID string, bookmark *Bookmark) error
+	// Delete removes a bookmark and reports false if there wasn't one
+	Delete(ctx context.Context, userID, messageID string) (bool, error)
+	DeleteForMessage(ctx context.Context, messageID string) error
+	DeleteForConversation(ctx context.Context, conversationID, userID string) error
+	// FindForUser pages through userID's bookmarks, newest first, continuing
+	// from before (ordered by CreatedAt and message ID). Bookmarks of deleted
+	// or hidden messages, or in conversations userID has left, are left out.
+	FindForUser(ctx context.Context, userID string, before *MessageCursor, limit int) ([]*Bookmark, error)
+}
@@ -21,6 +21,8 @@ type Conversation struct {
 	Participants []*User          `json:"participants,omitempty"`
 	UnreadCount  int              `json:"unread_count"`
 	Group        *Group           `json:"group,omitempty"` // Only for Group conversations
+	// Disappearing messages are deleted this long after being sent; 0 keeps them
+	MessageTTLSeconds int `json:"message_ttl_seconds,omitempty"`
 }
 
 type ConversationRepository interface {
@@ -31,8 +33,14 @@ type ConversationRepository interface {
 	FindForUser(ctx context.Context, userID string) ([]*Conversation, error)
 	FindByID(ctx context.Context, conversationID string) (*Conversation, error)
 	FindOneToOne(ctx context.Context, userID1, userID2 string) (string, error)
-	UpdateLastRead(ctx context.Context, conversationID, userID string) error
+	// AdvanceReceipt moves the user's delivered watermark (and read watermark, for
+	// ReceiptRead) forward to through, returning the other users who sent messages
+	// the move newly covers
+	AdvanceReceipt(ctx context.Context, conversationID, userID string, status ReceiptStatus, through time.Time) (senderIDs []string, err error)
+	GetReceipts(ctx context.Context, conversationID string) ([]*ParticipantReceipt, error)
 	Delete(ctx context.Context, conversationID string) error
 	GetLastReadTimestamp(ctx context.Context, conversationID, userID string) (time.Time, error)
 	IsUserInConversation(ctx context.Context, conversationID, userID string) (bool, error)
+	// SetMessageTTL sets the disappearing messages timer; 0 turns it off
+	SetMessageTTL(ctx context.Context, conversationID string, ttlSeconds int) error
 }
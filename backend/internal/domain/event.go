@@ -9,20 +9,35 @@ import (
 type EventType string
 
 const (
-	EventNewMessage          EventType = "new_message"
-	EventFriendRequest       EventType = "friend_request"
-	EventFriendAccepted      EventType = "friend_accepted"
-	EventGameInvite          EventType = "game_invite"
-	EventGameUpdate          EventType = "game_update"
-	EventGroupCreated        EventType = "group_created"
-	EventGroupJoined         EventType = "group_joined"
-	EventGroupLeft           EventType = "group_left"
-	EventConversationDeleted EventType = "conversation_deleted"
+	EventNewMessage             EventType = "new_message"
+	EventFriendRequest          EventType = "friend_request"
+	EventFriendAccepted         EventType = "friend_accepted"
+	EventGameInvite             EventType = "game_invite"
+	EventGameUpdate             EventType = "game_update"
+	EventGroupCreated           EventType = "group_created"
+	EventGroupJoined            EventType = "group_joined"
+	EventGroupLeft              EventType = "group_left"
+	EventConversationDeleted    EventType = "conversation_deleted"
+	EventMessageEdited          EventType = "message_edited"
+	EventMessageDeleted         EventType = "message_deleted"
+	EventReactionUpdated        EventType = "reaction_updated"
+	EventReceiptUpdated         EventType = "receipt_updated"
+	EventMessagePinned          EventType = "message_pinned"
+	EventMessageUnpinned        EventType = "message_unpinned"
+	EventPresenceUpdated        EventType = "presence_updated"
+	EventStatusUpdated          EventType = "status_updated"
+	EventScheduledMessageSent   EventType = "scheduled_message_sent"
+	EventScheduledMessageFailed EventType = "scheduled_message_failed"
+	EventMessageTTLUpdated      EventType = "message_ttl_updated"
+	EventMessagesExpired        EventType = "messages_expired"
+	EventTypingStarted          EventType = "typing_started" // Ephemeral: relayed live, never stored
+	EventTypingStopped          EventType = "typing_stopped" // Ephemeral: relayed live, never stored
 )
 
 type Event struct {
 	ID              string          `json:"id"`
-	UserID          string          `json:"user_id"` // The user this event is primarily relevant to
+	UserID          string          `json:"user_id"`       // The user this event is primarily relevant to
+	Seq             int64           `json:"seq,omitempty"` // Strictly increasing per user from 1, used as the delivery cursor; unset on ephemeral events
 	EventType       EventType       `json:"event_type"`
 	Payload         json.RawMessage `json:"payload"`
 	ServerTimestamp time.Time       `json:"server_timestamp"`
@@ -30,6 +45,23 @@ type Event struct {
 
 type EventRepository interface {
 	Create(ctx context.Context, event *Event) error
-	GetEventsForUser(ctx context.Context, userID string, sinceEventID string, limit int) ([]*Event, error)
+	GetEventsForUser(ctx context.Context, userID string, sinceSeq int64, limit int) ([]*Event, error)
 	GetEventByID(ctx context.Context, eventID string) (*Event, error)
 }
+
+// EventBus relays persisted events between backend replicas, so an event
+// created on one node reaches the recipient's connections on any node.
+type EventBus interface {
+	Publish(ctx context.Context, event *Event) error
+	SubscribeUser(ctx context.Context, userID string) error
+	UnsubscribeUser(ctx context.Context, userID string) error
+	Listen(ctx context.Context, handler func(event *Event))
+	Close() error
+}
+
+// EventNotifier wakes waiters as soon as an event for their user is stored.
+// The returned channel receives a signal per wakeup; call unsubscribe when done.
+type EventNotifier interface {
+	Subscribe(userID string) (wake <-chan struct{}, unsubscribe func())
+	Listen(ctx context.Context)
+}
@@ -2,20 +2,149 @@ package domain
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
 	"time"
+
+	"github.com/google/uuid"
 )
 
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type Message struct {
-	ID              string    `json:"id"`
-	ConversationID  string    `json:"conversation_id"`
-	SenderID        string    `json:"sender_id"`
-	Content         string    `json:"content"`
-	ServerTimestamp time.Time `json:"server_timestamp"`
-	Sender          *User     `json:"sender,omitempty"`
+	ID               string             `json:"id"`
+	ConversationID   string             `json:"conversation_id"`
+	SenderID         string             `json:"sender_id"`
+	Content          string             `json:"content"`
+	System           bool               `json:"system,omitempty"` // Generated by the server; SenderID is the user whose action caused it
+	ServerTimestamp  time.Time          `json:"server_timestamp"`
+	EditedAt         *time.Time         `json:"edited_at,omitempty"`      // Set once the sender has edited the message
+	DeletedAt        *time.Time         `json:"deleted_at,omitempty"`     // Set on tombstones of messages deleted for everyone
+	ExpiresAt        *time.Time         `json:"expires_at,omitempty"`     // When a disappearing message is deleted
+	ReplyToID        *string            `json:"reply_to_id,omitempty"`    // Message quoted inline by this one
+	ThreadRootID     *string            `json:"thread_root_id,omitempty"` // Set on replies posted in a sub-thread
+	ThreadReplyCount int                `json:"thread_reply_count"`       // Number of replies in this message's thread
+	ForwardedFrom    *MessageOrigin     `json:"forwarded_from,omitempty"` // Set on copies made by forwarding
+	Reactions        []*ReactionSummary `json:"reactions,omitempty"`
+	Attachments      []*Attachment      `json:"attachments,omitempty"`
+	PinnedAt         *time.Time         `json:"pinned_at,omitempty"`      // Set while the message is pinned
+	Cursor           string             `json:"cursor,omitempty"`         // Pass as before/after to page from this message
+	ReceiptStatus    ReceiptStatus      `json:"receipt_status,omitempty"` // Only on the viewer's own messages
+	Sender           *User              `json:"sender,omitempty"`
+}
+
+// MessageOrigin is where a forwarded message was first sent. Forwarding a
+// forwarded message keeps the original origin.
+type MessageOrigin struct {
+	MessageID      string `json:"message_id"`
+	ConversationID string `json:"conversation_id"`
+	SenderID       string `json:"sender_id"`
+	SenderUsername string `json:"sender_username,omitempty"` // Empty once the sender's account is gone
+}
+
+// MessageDeleteScope selects who a message is deleted for.
+type MessageDeleteScope string
+
+const (
+	DeleteForMe       MessageDeleteScope = "me"
+	DeleteForEveryone MessageDeleteScope = "everyone"
+)
+
+// MessageRevision is a previous content of an edited message.
+type MessageRevision struct {
+	ID        int64     `json:"id"`
+	MessageID string    `json:"message_id"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"` // When this content was written
+}
+
+// MessageCursor marks a position in a conversation's history. Comparing
+// (Timestamp, ID) pairs means messages sharing a timestamp are never skipped.
+type MessageCursor struct {
+	Timestamp time.Time
+	ID        string // Breaks ties between messages sent in the same instant
+}
+
+// String encodes the cursor as an opaque, URL-safe token.
+func (c MessageCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.Timestamp.UTC().Format(time.RFC3339Nano) + "|" + c.ID))
+}
+
+// ParseMessageCursor decodes a token made by MessageCursor.String.
+func ParseMessageCursor(token string) (*MessageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &MessageCursor{Timestamp: timestamp, ID: id}, nil
+}
+
+// MessageWindow is a slice of history around one message
+type MessageWindow struct {
+	Messages      []*Message `json:"messages"` // Chronological, including the target message
+	HasMoreBefore bool       `json:"has_more_before"`
+	HasMoreAfter  bool       `json:"has_more_after"`
+}
+
+type MessageSearchFilter struct {
+	Query          string    // Web-search syntax: words, "quoted phrases", -excluded, OR
+	ConversationID string    // Optional
+	SenderID       string    // Optional
+	From           time.Time // Optional, inclusive
+	To             time.Time // Optional, exclusive
+}
+
+type MessageSearchHit struct {
+	Message *Message `json:"message"`
+	Snippet string   `json:"snippet"` // HTML-escaped excerpt with matches wrapped in <mark>
+}
+
+type MessageSearchPage struct {
+	Results    []*MessageSearchHit `json:"results"`
+	NextCursor string              `json:"next_cursor,omitempty"` // Empty on the last page
 }
 
 type MessageRepository interface {
 	Create(ctx context.Context, message *Message) error
-	FindByConversationID(ctx context.Context, conversationID string, before time.Time, limit int) ([]*Message, error)
+	// CreateWithAttachments creates the message and links the unsent
+	// attachments to it in one transaction; it fails with
+	// ErrAttachmentAlreadySent if any of them was already sent.
+	CreateWithAttachments(ctx context.Context, message *Message, attachmentIDs []string) error
+	// CreateForwarded creates forwarded copies and their Attachments in one
+	// transaction.
+	CreateForwarded(ctx context.Context, messages []*Message) error
+	FindByID(ctx context.Context, messageID string) (*Message, error)
+	// FindByConversationID pages backwards from before (nil for the newest) through the main
+	// timeline (thread replies excluded), leaving out messages userID hid for themselves.
+	// Pages of history are always returned in chronological order.
+	FindByConversationID(ctx context.Context, conversationID, userID string, before *MessageCursor, limit int) ([]*Message, error)
+	// FindByConversationIDAfter pages forwards through the main timeline
+	FindByConversationIDAfter(ctx context.Context, conversationID, userID string, after MessageCursor, limit int) ([]*Message, error)
+	FindThreadReplies(ctx context.Context, rootMessageID, userID string, before *MessageCursor, limit int) ([]*Message, error)
+	FindThreadRepliesAfter(ctx context.Context, rootMessageID, userID string, after MessageCursor, limit int) ([]*Message, error)
 	GetLastMessage(ctx context.Context, conversationID string) (*Message, error)
+	UpdateContent(ctx context.Context, messageID, content string, editedAt time.Time) error
+	GetRevisions(ctx context.Context, messageID string) ([]*MessageRevision, error)
+	MarkDeleted(ctx context.Context, messageID string, deletedAt time.Time) error
+	HideForUser(ctx context.Context, messageID, userID string) error
+	Search(ctx context.Context, userID string, filter MessageSearchFilter, before *MessageCursor, limit int) ([]*MessageSearchHit, error)
+	// FindExpired returns the IDs of up to limit messages whose ExpiresAt has
+	// passed at now, plus the replies of any thread roots among them.
+	FindExpired(ctx context.Context, now time.Time, limit int) ([]string, error)
+	// DeleteExpired hard-deletes those of messageIDs that have expired and
+	// their thread replies, along with the events carrying their content,
+	// returning the deleted IDs by conversation.
+	DeleteExpired(ctx context.Context, messageIDs []string) (map[string][]string, error)
 }
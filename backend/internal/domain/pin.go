@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// PinnedMessage is a message pinned to the top of its conversation.
+type PinnedMessage struct {
+	Message  *Message  `json:"message"`
+	PinnedBy string    `json:"pinned_by"`
+	PinnedAt time.Time `json:"pinned_at"`
+}
+
+type PinRepository interface {
+	// Create pins pin.Message and reports false if it already was pinned, in
+	// which case PinnedBy and PinnedAt are replaced with the existing pin's.
+	Create(ctx context.Context, pin *PinnedMessage) (bool, error)
+	// Delete unpins the message and reports false if it wasn't pinned
+	Delete(ctx context.Context, messageID string) (bool, error)
+	CountForConversation(ctx context.Context, conversationID string) (int, error)
+	// FindForConversation lists the pins, most recently pinned first, leaving
+	// out deleted messages and those userID hid for themselves.
+	FindForConversation(ctx context.Context, conversationID, userID string) ([]*PinnedMessage, error)
+	// GetPinnedAt returns when each of the pinned messages among messageIDs was pinned
+	GetPinnedAt(ctx context.Context, messageIDs []string) (map[string]time.Time, error)
+}
@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type PresenceStatus string
+
+const (
+	PresenceOnline  PresenceStatus = "online"
+	PresenceAway    PresenceStatus = "away" // Connected, but every device reports the user idle
+	PresenceOffline PresenceStatus = "offline"
+)
+
+// Presence is what friends see of a user's connection state. Users who hide
+// their presence are always reported offline, without a last-seen time.
+type Presence struct {
+	UserID     string         `json:"user_id"`
+	Status     PresenceStatus `json:"status"`
+	LastSeenAt *time.Time     `json:"last_seen_at,omitempty"`
+	Hidden     bool           `json:"-"` // The user hides their presence from others
+}
+
+// PresenceStore keeps live presence in storage shared by every replica.
+// Each connection is tracked separately and lapses unless refreshed within
+// its TTL, so connections held by a crashed node eventually go offline.
+// A user's status is the most present of their connections.
+type PresenceStore interface {
+	// SetConnection records or refreshes one connection of the user and
+	// returns the user's status before and after the change.
+	SetConnection(ctx context.Context, userID, connectionID string, status PresenceStatus, ttl time.Duration) (before, after PresenceStatus, err error)
+	// RefreshConnection extends a connection that is still recorded and
+	// reports whether it was; it never brings back a removed one.
+	RefreshConnection(ctx context.Context, userID, connectionID string, ttl time.Duration) (bool, error)
+	// RemoveConnection forgets one connection of the user and returns the
+	// user's status before and after the change.
+	RemoveConnection(ctx context.Context, userID, connectionID string) (before, after PresenceStatus, err error)
+	GetStatuses(ctx context.Context, userIDs []string) (map[string]PresenceStatus, error)
+	// ClaimExpired returns users whose connections may all have lapsed by
+	// now. Each such user is handed to a single caller across replicas.
+	ClaimExpired(ctx context.Context, now time.Time, limit int) ([]string, error)
+}
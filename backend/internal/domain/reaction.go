@@ -0,0 +1,19 @@
+package domain
+
+import (
+	"context"
+)
+
+// ReactionSummary aggregates the reactions with one emoji on a message.
+type ReactionSummary struct {
+	Emoji   string   `json:"emoji"`
+	Count   int      `json:"count"`
+	UserIDs []string `json:"user_ids"` // In the order they reacted
+}
+
+type ReactionRepository interface {
+	Add(ctx context.Context, messageID, userID, emoji string) error
+	Remove(ctx context.Context, messageID, userID, emoji string) error
+	// GetForMessages returns the reaction summaries keyed by message ID
+	GetForMessages(ctx context.Context, messageIDs []string) (map[string][]*ReactionSummary, error)
+}
@@ -0,0 +1,51 @@
+package domain
+
+import "time"
+
+type ReceiptStatus string
+
+const (
+	ReceiptSent      ReceiptStatus = "sent"
+	ReceiptDelivered ReceiptStatus = "delivered"
+	ReceiptRead      ReceiptStatus = "read"
+)
+
+// ParticipantReceipt records how far a participant has received and read a
+// conversation; every message sent at or before a watermark is covered by it.
+type ParticipantReceipt struct {
+	User             *User
+	DeliveredThrough time.Time
+	ReadThrough      time.Time
+}
+
+// StatusOf reports the participant's receipt status for a message sent at sentAt.
+func (p *ParticipantReceipt) StatusOf(sentAt time.Time) ReceiptStatus {
+	switch {
+	case !p.ReadThrough.Before(sentAt):
+		return ReceiptRead
+	case !p.DeliveredThrough.Before(sentAt):
+		return ReceiptDelivered
+	default:
+		return ReceiptSent
+	}
+}
+
+// MessageReceipt is one recipient's status for a message
+type MessageReceipt struct {
+	User   *User         `json:"user"`
+	Status ReceiptStatus `json:"status"`
+}
+
+type MessageReceipts struct {
+	Status     ReceiptStatus     `json:"status"` // The least advanced status across recipients
+	Recipients []*MessageReceipt `json:"recipients"`
+	SeenBy     []*User           `json:"seen_by"` // Recipients who have read the message
+}
+
+// ReceiptUpdate is sent to senders when a participant's watermark advances
+type ReceiptUpdate struct {
+	ConversationID string        `json:"conversation_id"`
+	UserID         string        `json:"user_id"` // The participant who received or read
+	Status         ReceiptStatus `json:"status"`
+	Through        time.Time     `json:"through"` // Applies to the sender's messages sent at or before this
+}
@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// ScheduledMessage is a message composed now to be sent into a conversation
+// at SendAt. It exists only until it has been sent or has failed to send.
+type ScheduledMessage struct {
+	ID             string    `json:"id"`
+	ConversationID string    `json:"conversation_id"`
+	SenderID       string    `json:"sender_id"`
+	Content        string    `json:"content"`
+	SendAt         time.Time `json:"send_at"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type ScheduledMessageRepository interface {
+	Create(ctx context.Context, scheduled *ScheduledMessage) error
+	FindByID(ctx context.Context, id string) (*ScheduledMessage, error)
+	// FindForUser lists the user's scheduled messages, soonest first
+	FindForUser(ctx context.Context, userID string) ([]*ScheduledMessage, error)
+	// Update replaces the content and send time, and reports false if the
+	// message is being sent at now (or no longer exists).
+	Update(ctx context.Context, scheduled *ScheduledMessage, now time.Time) (bool, error)
+	// Cancel deletes the message unless it is being sent at now, reporting
+	// whether it did.
+	Cancel(ctx context.Context, id string, now time.Time) (bool, error)
+	// ClaimDue hands out up to limit messages due at now, hiding them from
+	// other callers until claimedUntil. Unless completed by then they are
+	// handed out again, so a send interrupted by a crash is retried.
+	ClaimDue(ctx context.Context, now, claimedUntil time.Time, limit int) ([]*ScheduledMessage, error)
+	// Complete deletes a claimed message once it has been sent or given up on
+	Complete(ctx context.Context, id string) error
+}
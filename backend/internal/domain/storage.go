@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrBlobNotFound   = errors.New("blob not found")
+	ErrInvalidBlobKey = errors.New("invalid blob key")
+)
+
+// IsCleanBlobKey reports whether key is a relative path that path.Clean leaves
+// unchanged and that has no ".." element, so checks on its prefix hold for
+// whatever it resolves to.
+func IsCleanBlobKey(key string) bool {
+	if key == "" || key == "." || strings.HasPrefix(key, "/") || path.Clean(key) != key {
+		return false
+	}
+	return !slices.Contains(strings.Split(key, "/"), "..")
+}
+
+// BlobStorage holds uploaded file contents under opaque keys such as
+// "avatars/<id>.png", so replicas don't depend on a shared local disk.
+type BlobStorage interface {
+	Put(ctx context.Context, key, contentType string, content io.Reader, size int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a URL that grants read access to the blob until it expires
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
@@ -6,13 +6,15 @@ import (
 )
 
 type User struct {
-	ID                string    `json:"id"`
-	Username          string    `json:"username"`
-	Email             string    `json:"email"`
-	PasswordHash      string    `json:"-"`
-	ProfilePictureURL string    `json:"profile_picture_url"`
-	IsVerified        bool      `json:"is_verified"`
-	CreatedAt         time.Time `json:"created_at"`
+	ID                string      `json:"id"`
+	Username          string      `json:"username"`
+	Email             string      `json:"email"`
+	PasswordHash      string      `json:"-"`
+	ProfilePictureURL string      `json:"profile_picture_url"`
+	IsVerified        bool        `json:"is_verified"`
+	HidePresence      bool        `json:"hide_presence,omitempty"` // Privacy: friends see the user as offline, with no last-seen time
+	Status            *UserStatus `json:"status,omitempty"`        // Custom status and do-not-disturb; set where user lists load it
+	CreatedAt         time.Time   `json:"created_at"`
 }
 
 type UserRepository interface {
@@ -21,8 +23,15 @@ type UserRepository interface {
 	FindByID(ctx context.Context, id string) (*User, error)
 	FindByName(ctx context.Context, name string) (*User, error)
 	UpdateVerificationStatus(ctx context.Context, userID string, isVerified bool) error
-	UpdateProfilePicture(ctx context.Context, userID, url string) error
+	// UpdateProfilePicture sets the URL and returns the one it replaced
+	UpdateProfilePicture(ctx context.Context, userID, url string) (previousURL string, err error)
 	UpdatePassword(ctx context.Context, userID, newPasswordHash string) error
+	UpdateLastSeen(ctx context.Context, userID string, at time.Time) error
+	UpdatePresenceVisibility(ctx context.Context, userID string, hidden bool) error
+	UpdateStatus(ctx context.Context, userID string, status *UserStatus) error
+	// FindPresence returns last-seen times and privacy settings; the live
+	// status is left for the caller to fill in.
+	FindPresence(ctx context.Context, userIDs []string) ([]*Presence, error)
 	// For simplicity, profile picture and password updates are here.
 	// In a larger app, these might be in a separate ProfileRepository.
 }
@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInvalidDNDSchedule = errors.New("do-not-disturb schedule needs HH:MM start and end times and a valid time zone")
+
+// UserStatus is the custom status a user shows to others, together with
+// their do-not-disturb setting. Do-not-disturb never holds back in-app events
+// or account emails (verification, password reset), and the server sends no
+// other email; clients use DNDActive to mute sounds and alerts.
+type UserStatus struct {
+	Text         string       `json:"text,omitempty"`
+	Emoji        string       `json:"emoji,omitempty"`
+	ExpiresAt    *time.Time   `json:"expires_at,omitempty"`   // Text and emoji are cleared after this
+	DoNotDisturb bool         `json:"do_not_disturb"`         // Manual switch, on until turned off
+	DNDSchedule  *DNDSchedule `json:"dnd_schedule,omitempty"` // Recurring daily quiet hours
+	DNDActive    bool         `json:"dnd_active"`             // Derived: the switch is on or the schedule applies right now
+}
+
+// DNDSchedule is a daily window, in the user's time zone, during which
+// do-not-disturb applies.
+type DNDSchedule struct {
+	Start    string `json:"start"`     // "HH:MM"
+	End      string `json:"end"`       // "HH:MM"; earlier than Start wraps past midnight
+	TimeZone string `json:"time_zone"` // IANA name, e.g. "Europe/Berlin"; UTC when empty
+}
+
+type UserStatusUpdate struct {
+	UserID string      `json:"user_id"`
+	Status *UserStatus `json:"status"`
+}
+
+// Resolve drops a custom status that has expired and works out whether
+// do-not-disturb is in effect at now.
+func (s *UserStatus) Resolve(now time.Time) {
+	if s.ExpiresAt != nil && !now.Before(*s.ExpiresAt) {
+		s.Text, s.Emoji, s.ExpiresAt = "", "", nil
+	}
+	s.DNDActive = s.DoNotDisturb || (s.DNDSchedule != nil && s.DNDSchedule.Contains(now))
+}
+
+// Validate checks the times and time zone of the schedule.
+func (d *DNDSchedule) Validate() error {
+	_, _, _, err := d.parse()
+	return err
+}
+
+// Contains reports whether t falls inside the daily window. An invalid
+// schedule contains nothing.
+func (d *DNDSchedule) Contains(t time.Time) bool {
+	start, end, loc, err := d.parse()
+	if err != nil || start == end {
+		return false
+	}
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	if start < end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// parse returns the window as minutes since local midnight.
+func (d *DNDSchedule) parse() (start, end int, loc *time.Location, err error) {
+	startTime, err := time.Parse("15:04", d.Start)
+	if err != nil {
+		return 0, 0, nil, ErrInvalidDNDSchedule
+	}
+	endTime, err := time.Parse("15:04", d.End)
+	if err != nil {
+		return 0, 0, nil, ErrInvalidDNDSchedule
+	}
+	loc, err = time.LoadLocation(d.TimeZone)
+	if err != nil {
+		return 0, 0, nil, ErrInvalidDNDSchedule
+	}
+	return startTime.Hour()*60 + startTime.Minute(), endTime.Hour()*60 + endTime.Minute(), loc, nil
+}
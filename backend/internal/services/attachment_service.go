@@ -0,0 +1,166 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	_ "image/gif" // Register decoders so image dimensions can be read
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"real-time-chat/internal/domain"
+	"real-time-chat/internal/usecase"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAttachmentTooLarge = errors.New("attachment exceeds the maximum allowed size")
+	ErrAttachmentEmpty    = errors.New("attachment is empty")
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	ErrInvalidAttachment  = errors.New("attachments must be unsent uploads of yours in this conversation")
+	ErrTooManyAttachments = errors.New("a message can have at most 10 attachments")
+)
+
+const maxAttachmentsPerMessage = 10
+
+const attachmentKeyPrefix = "attachments/"
+
+type attachmentService struct {
+	attachmentRepo domain.AttachmentRepository
+	convoRepo      domain.ConversationRepository
+	storage        domain.BlobStorage
+	maxSize        int64
+	urlExpiry      time.Duration // Lifetime of signed download URLs
+}
+
+func NewAttachmentService(attachmentRepo domain.AttachmentRepository, convoRepo domain.ConversationRepository, storage domain.BlobStorage, maxSize int64, urlExpiry time.Duration) usecase.AttachmentUseCase {
+	return &attachmentService{attachmentRepo: attachmentRepo, convoRepo: convoRepo, storage: storage, maxSize: maxSize, urlExpiry: urlExpiry}
+}
+
+// Upload stores the file and records its metadata. The attachment is linked
+// to a message once the uploader sends one referencing it.
+func (s *attachmentService) Upload(ctx context.Context, conversationID, userID, fileName string, content io.Reader) (*domain.Attachment, error) {
+	isParticipant, err := s.convoRepo.IsUserInConversation(ctx, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !isParticipant {
+		return nil, ErrNotParticipant
+	}
+
+	attachment := &domain.Attachment{
+		ID:             uuid.NewString(),
+		ConversationID: conversationID,
+		UploaderID:     userID,
+		FileName:       filepath.Base(fileName),
+		CreatedAt:      time.Now().UTC(),
+	}
+	attachment.StorageKey = attachmentKeyPrefix + attachment.ID
+
+	// Spool to a temporary file to learn the size, checksum and dimensions
+	// before handing the content to storage
+	tmp, err := os.CreateTemp("", "attachment-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	// Sniff the type from the content rather than trusting the client
+	reader := bufio.NewReaderSize(io.LimitReader(content, s.maxSize+1), 512)
+	head, _ := reader.Peek(512)
+	attachment.MimeType = http.DetectContentType(head)
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), reader)
+	if err != nil {
+		return nil, err
+	}
+	if size > s.maxSize {
+		return nil, ErrAttachmentTooLarge
+	}
+	if size == 0 {
+		return nil, ErrAttachmentEmpty
+	}
+	attachment.SizeBytes = size
+	attachment.Checksum = hex.EncodeToString(hasher.Sum(nil))
+
+	if strings.HasPrefix(attachment.MimeType, "image/") {
+		if _, err := tmp.Seek(0, io.SeekStart); err == nil {
+			if cfg, _, err := image.DecodeConfig(tmp); err == nil {
+				attachment.Width, attachment.Height = &cfg.Width, &cfg.Height
+			}
+		}
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err := s.storage.Put(ctx, attachment.StorageKey, attachment.MimeType, tmp, size); err != nil {
+		return nil, err
+	}
+
+	if err := s.attachmentRepo.Create(ctx, attachment); err != nil {
+		s.storage.Delete(ctx, attachment.StorageKey) // Clean up stored file if DB insert fails
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// Open returns the attachment and its content.
+func (s *attachmentService) Open(ctx context.Context, attachmentID, userID string) (*domain.Attachment, io.ReadCloser, error) {
+	attachment, err := s.authorize(ctx, attachmentID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := s.storage.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		log.Printf("Could not read content of attachment %s: %v", attachment.ID, err)
+		return nil, nil, ErrAttachmentNotFound
+	}
+	return attachment, content, nil
+}
+
+// SignedURL returns a short-lived URL the client can download the attachment from directly.
+func (s *attachmentService) SignedURL(ctx context.Context, attachmentID, userID string) (string, time.Time, error) {
+	attachment, err := s.authorize(ctx, attachmentID, userID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(s.urlExpiry)
+	url, err := s.storage.SignedURL(ctx, attachment.StorageKey, s.urlExpiry)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return url, expiresAt, nil
+}
+
+// authorize looks up an attachment readable by userID. Only participants of the
+// conversation may read it, and unsent uploads only by their uploader.
+func (s *attachmentService) authorize(ctx context.Context, attachmentID, userID string) (*domain.Attachment, error) {
+	attachment, err := s.attachmentRepo.FindByID(ctx, attachmentID)
+	if err != nil {
+		return nil, ErrAttachmentNotFound
+	}
+	if attachment.MessageID == nil && attachment.UploaderID != userID {
+		return nil, ErrAttachmentNotFound
+	}
+	isParticipant, err := s.convoRepo.IsUserInConversation(ctx, attachment.ConversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !isParticipant {
+		return nil, ErrNotParticipant
+	}
+	return attachment, nil
+}
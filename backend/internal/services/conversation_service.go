@@ -4,20 +4,24 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"real-time-chat/internal/domain"
 	"real-time-chat/internal/usecase"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type conversationService struct {
-	convoRepo domain.ConversationRepository
-	userRepo  domain.UserRepository
-	groupRepo domain.GroupRepository
+	convoRepo    domain.ConversationRepository
+	userRepo     domain.UserRepository
+	groupRepo    domain.GroupRepository
+	bookmarkRepo domain.BookmarkRepository
+	eventService usecase.EventUseCase // For streaming read receipts to senders
 }
 
-func NewConversationService(convoRepo domain.ConversationRepository, userRepo domain.UserRepository, groupRepo domain.GroupRepository) usecase.ConversationUseCase {
-	return &conversationService{convoRepo: convoRepo, userRepo: userRepo, groupRepo: groupRepo}
+func NewConversationService(convoRepo domain.ConversationRepository, userRepo domain.UserRepository, groupRepo domain.GroupRepository, bookmarkRepo domain.BookmarkRepository, eventService usecase.EventUseCase) usecase.ConversationUseCase {
+	return &conversationService{convoRepo: convoRepo, userRepo: userRepo, groupRepo: groupRepo, bookmarkRepo: bookmarkRepo, eventService: eventService}
 }
 
 func (s *conversationService) GetUserConversations(ctx context.Context, userID string) ([]*domain.Conversation, error) {
@@ -63,16 +67,41 @@ func (s *conversationService) AddParticipant(ctx context.Context, conversationID
 	return s.convoRepo.AddParticipant(ctx, conversationID, userID)
 }
 
+// RemoveParticipant takes userID out of the conversation, along with the
+// bookmarks they saved in it.
 func (s *conversationService) RemoveParticipant(ctx context.Context, conversationID, userID string) error {
-	return s.convoRepo.RemoveParticipant(ctx, conversationID, userID)
+	if err := s.convoRepo.RemoveParticipant(ctx, conversationID, userID); err != nil {
+		return err
+	}
+	if err := s.bookmarkRepo.DeleteForConversation(ctx, conversationID, userID); err != nil {
+		log.Printf("error removing bookmarks of user %s in conversation %s: %v", userID, conversationID, err)
+	}
+	return nil
 }
 
 func (s *conversationService) GetParticipantIDs(ctx context.Context, conversationID string) ([]string, error) {
 	return s.convoRepo.GetParticipantIDs(ctx, conversationID)
 }
 
+// MarkConversationAsRead marks every message sent so far as read by userID and
+// tells the senders of the newly read messages.
 func (s *conversationService) MarkConversationAsRead(ctx context.Context, conversationID, userID string) error {
-	return s.convoRepo.UpdateLastRead(ctx, conversationID, userID)
+	update := &domain.ReceiptUpdate{ConversationID: conversationID, UserID: userID, Status: domain.ReceiptRead, Through: time.Now().UTC()}
+	senderIDs, err := s.convoRepo.AdvanceReceipt(ctx, conversationID, userID, update.Status, update.Through)
+	if err != nil {
+		return err
+	}
+	notifyReceipt(ctx, s.eventService, senderIDs, update)
+	return nil
+}
+
+// notifyReceipt sends a receipt update to the senders of the messages it covers.
+func notifyReceipt(ctx context.Context, eventService usecase.EventUseCase, senderIDs []string, update *domain.ReceiptUpdate) {
+	for _, senderID := range senderIDs {
+		if err := eventService.CreateEvent(ctx, senderID, domain.EventReceiptUpdated, update); err != nil {
+			log.Printf("Failed to create receipt event for user %s: %v", senderID, err)
+		}
+	}
 }
 
 func (s *conversationService) CreateOneToOneConversation(ctx context.Context, userID1, userID2 string) (string, error) {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"real-time-chat/internal/domain"
 	"real-time-chat/internal/usecase"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,10 +15,14 @@ import (
 type eventService struct {
 	eventRepo domain.EventRepository
 	userRepo  domain.UserRepository // To enrich user data in events if needed
+	notifier  domain.EventNotifier  // Wakes waiters when new events are stored
+
+	mu        sync.RWMutex
+	listeners []usecase.EventListener // Notified after every persisted event
 }
 
-func NewEventService(eventRepo domain.EventRepository, userRepo domain.UserRepository) usecase.EventUseCase {
-	return &eventService{eventRepo: eventRepo, userRepo: userRepo}
+func NewEventService(eventRepo domain.EventRepository, userRepo domain.UserRepository, notifier domain.EventNotifier) usecase.EventUseCase {
+	return &eventService{eventRepo: eventRepo, userRepo: userRepo, notifier: notifier}
 }
 
 func (s *eventService) CreateEvent(ctx context.Context, userID string, eventType domain.EventType, payload interface{}) error {
@@ -34,31 +39,61 @@ func (s *eventService) CreateEvent(ctx context.Context, userID string, eventType
 		ServerTimestamp: time.Now().UTC(),
 	}
 
-	return s.eventRepo.Create(ctx, event)
+	if err := s.eventRepo.Create(ctx, event); err != nil {
+		return err
+	}
+
+	// Fan the persisted event out to live transports
+	s.mu.RLock()
+	listeners := s.listeners
+	s.mu.RUnlock()
+	for _, listener := range listeners {
+		listener(event)
+	}
+	return nil
 }
 
-func (s *eventService) GetEventsForUser(ctx context.Context, userID string, sinceEventID string, limit int) ([]*domain.Event, error) {
-	// If sinceEventID is provided, find its timestamp to use as a cursor
-	// var sinceTimestamp time.Time
-	// if sinceEventID != "" {
-	// 	event, err := s.eventRepo.GetEventByID(ctx, sinceEventID)
-	// 	if err != nil {
-	// 		if errors.Is(err, errors.New("event not found")) { // Specific error from repository
-	// 			return nil, errors.New("invalid since_event_id")
-	// 		}
-	// 		return nil, err
-	// 	}
-	// 	sinceTimestamp = event.ServerTimestamp
-	// } else {
-	// 	// If no sinceEventID, get events from the very beginning (or a reasonable default past)
-	// 	// For simplicity, if not provided, just get the latest `limit` events.
-	// 	// A more robust client would always provide the last known event ID.
-	// 	sinceTimestamp = time.Time{} // Zero time means no lower bound on timestamp for initial fetch
-	// }
+func (s *eventService) AddListener(listener usecase.EventListener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
 
+func (s *eventService) GetEventsForUser(ctx context.Context, userID string, sinceSeq int64, limit int) ([]*domain.Event, error) {
 	if limit == 0 {
 		limit = 50 // Default limit for event feed
 	}
 
-	return s.eventRepo.GetEventsForUser(ctx, userID, sinceEventID, limit) // repository will handle timestamp/ID logic
+	// sinceSeq is the last sequence number the client has seen; 0 means no cursor
+	return s.eventRepo.GetEventsForUser(ctx, userID, sinceSeq, limit)
+}
+
+// WaitForEvents returns events after sinceSeq, blocking until at least one is
+// available or ctx is done, in which case the result is empty rather than an
+// error. It only re-queries when the notifier signals a new event for the
+// user, so idle waiters cost nothing.
+func (s *eventService) WaitForEvents(ctx context.Context, userID string, sinceSeq int64, limit int) ([]*domain.Event, error) {
+	// Subscribe before the first query so an event stored in between still wakes us
+	wake, unsubscribe := s.notifier.Subscribe(userID)
+	defer unsubscribe()
+
+	for {
+		events, err := s.GetEventsForUser(ctx, userID, sinceSeq, limit)
+		if err != nil {
+			if ctx.Err() != nil {
+				// The wait ran out while querying; that's a timeout, not a failure
+				return []*domain.Event{}, nil
+			}
+			return nil, err
+		}
+		if len(events) > 0 {
+			return events, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return []*domain.Event{}, nil
+		case <-wake:
+		}
+	}
 }
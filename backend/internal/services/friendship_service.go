@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"errors"
+	"log"
 	"real-time-chat/internal/domain"
 	"real-time-chat/internal/usecase"
 
@@ -127,3 +128,19 @@ func (s *friendshipService) GetPendingRequests(ctx context.Context, userID strin
 func (s *friendshipService) GetFriends(ctx context.Context, userID string) ([]*domain.User, error) {
 	return s.friendshipRepo.GetFriends(ctx, userID)
 }
+
+// sendToFriends creates an event with the same payload for each of the
+// user's friends. Failures are logged, not returned: the change that
+// triggered the event has already been stored.
+func sendToFriends(ctx context.Context, friendshipRepo domain.FriendshipRepository, eventService usecase.EventUseCase, userID string, eventType domain.EventType, payload interface{}) {
+	friends, err := friendshipRepo.GetFriends(ctx, userID)
+	if err != nil {
+		log.Printf("error getting friends of user %s for %s event: %v", userID, eventType, err)
+		return
+	}
+	for _, friend := range friends {
+		if err := eventService.CreateEvent(ctx, friend.ID, eventType, payload); err != nil {
+			log.Printf("Failed to create %s event for user %s: %v", eventType, friend.ID, err)
+		}
+	}
+}
@@ -6,33 +6,102 @@ import (
 	"log"
 	"real-time-chat/internal/domain"
 	"real-time-chat/internal/usecase"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+var (
+	ErrMessageTooLong      = errors.New("message content exceeds 500 characters")
+	ErrMessageEmpty        = errors.New("message content cannot be empty")
+	ErrMessageNotFound     = errors.New("message not found")
+	ErrNotParticipant      = errors.New("user is not a participant of this conversation")
+	ErrNotMessageSender    = errors.New("only the sender can edit this message")
+	ErrEditWindowExpired   = errors.New("message can no longer be edited")
+	ErrMessageEditNoChange = errors.New("message content is unchanged")
+	ErrMessageDeleted      = errors.New("message has been deleted")
+	ErrCannotDeleteMessage = errors.New("only the sender or the group owner can delete this message for everyone")
+	ErrInvalidDeleteScope  = errors.New("invalid delete scope, must be 'me' or 'everyone'")
+	ErrInvalidParent       = errors.New("replied-to message must belong to the same conversation")
+	ErrInvalidEmoji        = errors.New("reaction emoji must be 1-32 bytes with no whitespace")
+	ErrSearchQueryEmpty    = errors.New("search query cannot be empty")
+	ErrConflictingCursors  = errors.New("use either a before or an after cursor, not both")
+	ErrCannotPin           = errors.New("only the group owner can pin or unpin messages in a group")
+	ErrTooManyPins         = errors.New("a conversation can have at most 50 pinned messages")
+	ErrBookmarkNoteTooLong = errors.New("bookmark note exceeds 500 characters")
+	ErrSystemMessage       = errors.New("system messages cannot be edited")
+	ErrInvalidMessageTTL   = errors.New("disappearing messages timer must be 0 (off), 3600, 86400 or 604800 seconds")
+	ErrMessageTTLNoChange  = errors.New("disappearing messages timer is already set to that")
+	ErrCannotSetMessageTTL = errors.New("only the group owner can change disappearing messages in a group")
+	ErrNothingToForward    = errors.New("choose at least one message and one conversation to forward to")
+	ErrTooManyForwards     = errors.New("at most 20 messages can be forwarded to at most 10 conversations at once")
+	ErrCannotForwardSystem = errors.New("system messages cannot be forwarded")
+	ErrForwardedMessage    = errors.New("forwarded messages cannot be edited")
+)
+
+const (
+	maxPageSize            = 100 // Upper bound on messages returned per history page
+	maxPinsPerConversation = 50
+	maxBookmarkNoteLength  = 500
+	messageExpiryBatch     = 500 // Expired messages deleted per round trip
+	maxForwardMessages     = 20
+	maxForwardTargets      = 10
+)
+
+// messageTTLLabels names the disappearing messages timers, in seconds, a
+// conversation can be set to.
+var messageTTLLabels = map[int]string{
+	3600:   "1 hour",
+	86400:  "24 hours",
+	604800: "7 days",
+}
+
 type messageService struct {
-	messageRepo domain.MessageRepository
-	convoRepo   domain.ConversationRepository
-	userRepo    domain.UserRepository // Added for fetching sender details
+	messageRepo  domain.MessageRepository
+	convoRepo    domain.ConversationRepository
+	userRepo     domain.UserRepository  // Added for fetching sender details
+	groupRepo    domain.GroupRepository // Group owners may delete any message in their group
+	reactionRepo domain.ReactionRepository
+	attachRepo   domain.AttachmentRepository
+	pinRepo      domain.PinRepository
+	bookmarkRepo domain.BookmarkRepository
+	storage      domain.BlobStorage   // Attachment contents, dropped with the last message using them
+	eventService usecase.EventUseCase // For notifying participants of message changes
+	editWindow   time.Duration        // How long after sending a message may be edited
 }
 
-func NewMessageService(messageRepo domain.MessageRepository, convoRepo domain.ConversationRepository, userRepo domain.UserRepository) usecase.MessageUseCase {
-	return &messageService{messageRepo: messageRepo, convoRepo: convoRepo, userRepo: userRepo}
+func NewMessageService(messageRepo domain.MessageRepository, convoRepo domain.ConversationRepository, userRepo domain.UserRepository, groupRepo domain.GroupRepository, reactionRepo domain.ReactionRepository, attachRepo domain.AttachmentRepository, pinRepo domain.PinRepository, bookmarkRepo domain.BookmarkRepository, storage domain.BlobStorage, eventService usecase.EventUseCase, editWindow time.Duration) usecase.MessageUseCase {
+	return &messageService{messageRepo: messageRepo, convoRepo: convoRepo, userRepo: userRepo, groupRepo: groupRepo, reactionRepo: reactionRepo, attachRepo: attachRepo, pinRepo: pinRepo, bookmarkRepo: bookmarkRepo, storage: storage, eventService: eventService, editWindow: editWindow}
 }
 
 func (s *messageService) SaveMessage(ctx context.Context, message *domain.Message) (*domain.Message, error) {
 	if len(message.Content) > 500 {
-		return nil, errors.New("message content exceeds 500 characters")
+		return nil, ErrMessageTooLong
+	}
+	if err := s.ensureParticipant(ctx, message.ConversationID, message.SenderID); err != nil {
+		return nil, err
+	}
+	if err := s.resolveParents(ctx, message); err != nil {
+		return nil, err
+	}
+	attachmentIDs, err := s.checkAttachments(ctx, message)
+	if err != nil {
+		return nil, err
 	}
 
 	message.ID = uuid.NewString()
 	message.ServerTimestamp = time.Now().UTC()
+	message.Cursor = domain.MessageCursor{Timestamp: message.ServerTimestamp, ID: message.ID}.String()
 
-	err := s.messageRepo.Create(ctx, message)
-	if err != nil {
+	// An error means nothing was saved, so the send can be retried
+	if err := s.messageRepo.CreateWithAttachments(ctx, message, attachmentIDs); err != nil {
 		return nil, err
 	}
+	for _, attachment := range message.Attachments {
+		attachment.MessageID = &message.ID
+	}
 
 	// Populate sender details for the returned message
 	sender, err := s.userRepo.FindByID(ctx, message.SenderID)
@@ -46,30 +115,936 @@ func (s *messageService) SaveMessage(ctx context.Context, message *domain.Messag
 	return message, nil
 }
 
-func (s *messageService) GetMessagesForConversation(ctx context.Context, conversationID, userID string, before time.Time, limit int) ([]*domain.Message, error) {
-	// Check if user is a participant in the conversation
-	isParticipant, err := s.convoRepo.IsUserInConversation(ctx, conversationID, userID)
+// GetMessagesForConversation pages through the main timeline: backwards from
+// before (nil for the newest messages), or forwards from after when it is set.
+func (s *messageService) GetMessagesForConversation(ctx context.Context, conversationID, userID string, before, after *domain.MessageCursor, limit int) ([]*domain.Message, error) {
+	if before != nil && after != nil {
+		return nil, ErrConflictingCursors
+	}
+	if err := s.ensureParticipant(ctx, conversationID, userID); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || limit > maxPageSize {
+		limit = 20
+	}
+
+	var messages []*domain.Message
+	var err error
+	if after != nil {
+		messages, err = s.messageRepo.FindByConversationIDAfter(ctx, conversationID, userID, *after, limit)
+	} else {
+		messages, err = s.messageRepo.FindByConversationID(ctx, conversationID, userID, before, limit)
+	}
 	if err != nil {
 		return nil, err
 	}
-	if !isParticipant {
-		return nil, errors.New("user is not a participant of this conversation")
+	if err := s.attachDetails(ctx, messages); err != nil {
+		return nil, err
 	}
+	if err := s.attachReceiptStatus(ctx, conversationID, userID, messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
 
-	if before.IsZero() {
-		before = time.Now().UTC()
+// GetThreadReplies pages through the replies of a thread root, like
+// GetMessagesForConversation does for the main timeline.
+func (s *messageService) GetThreadReplies(ctx context.Context, rootMessageID, userID string, before, after *domain.MessageCursor, limit int) ([]*domain.Message, error) {
+	if before != nil && after != nil {
+		return nil, ErrConflictingCursors
 	}
-	if limit == 0 {
+	root, err := s.messageRepo.FindByID(ctx, rootMessageID)
+	if err != nil {
+		return nil, ErrMessageNotFound
+	}
+	if err := s.ensureParticipant(ctx, root.ConversationID, userID); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || limit > maxPageSize {
 		limit = 20
 	}
+	var replies []*domain.Message
+	if after != nil {
+		replies, err = s.messageRepo.FindThreadRepliesAfter(ctx, rootMessageID, userID, *after, limit)
+	} else {
+		replies, err = s.messageRepo.FindThreadReplies(ctx, rootMessageID, userID, before, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachDetails(ctx, replies); err != nil {
+		return nil, err
+	}
+	if err := s.attachReceiptStatus(ctx, root.ConversationID, userID, replies); err != nil {
+		return nil, err
+	}
+	return replies, nil
+}
 
-	messages, err := s.messageRepo.FindByConversationID(ctx, conversationID, before, limit)
+// GetMessagesAround returns the message with up to limit messages on each
+// side of it, from its thread if it is a thread reply or else from the main
+// timeline. Used to jump to search results, quoted messages and pins.
+func (s *messageService) GetMessagesAround(ctx context.Context, messageID, userID string, limit int) (*domain.MessageWindow, error) {
+	target, err := s.messageRepo.FindByID(ctx, messageID)
 	if err != nil {
+		return nil, ErrMessageNotFound
+	}
+	if err := s.ensureParticipant(ctx, target.ConversationID, userID); err != nil {
 		return nil, err
 	}
-	return messages, nil
+	if limit <= 0 || limit > maxPageSize {
+		limit = 25
+	}
+
+	// Fetch one extra message on each side to learn whether more follow
+	cursor := domain.MessageCursor{Timestamp: target.ServerTimestamp, ID: target.ID}
+	var before, after []*domain.Message
+	if target.ThreadRootID != nil {
+		before, err = s.messageRepo.FindThreadReplies(ctx, *target.ThreadRootID, userID, &cursor, limit+1)
+		if err == nil {
+			after, err = s.messageRepo.FindThreadRepliesAfter(ctx, *target.ThreadRootID, userID, cursor, limit+1)
+		}
+	} else {
+		before, err = s.messageRepo.FindByConversationID(ctx, target.ConversationID, userID, &cursor, limit+1)
+		if err == nil {
+			after, err = s.messageRepo.FindByConversationIDAfter(ctx, target.ConversationID, userID, cursor, limit+1)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	window := &domain.MessageWindow{HasMoreBefore: len(before) > limit, HasMoreAfter: len(after) > limit}
+	if window.HasMoreBefore {
+		before = before[1:] // Chronological, so the extra one is the oldest
+	}
+	if window.HasMoreAfter {
+		after = after[:limit]
+	}
+	window.Messages = append(append(before, target), after...)
+	if err := s.attachDetails(ctx, window.Messages); err != nil {
+		return nil, err
+	}
+	if err := s.attachReceiptStatus(ctx, target.ConversationID, userID, window.Messages); err != nil {
+		return nil, err
+	}
+	return window, nil
+}
+
+// resolveParents checks that the quoted message and thread root belong to the
+// message's conversation. Replies to a message inside a thread go to that
+// thread's root, so threads stay one level deep.
+func (s *messageService) resolveParents(ctx context.Context, message *domain.Message) error {
+	if message.ReplyToID != nil {
+		parent, err := s.messageRepo.FindByID(ctx, *message.ReplyToID)
+		if err != nil || parent.ConversationID != message.ConversationID {
+			return ErrInvalidParent
+		}
+	}
+	if message.ThreadRootID != nil {
+		root, err := s.messageRepo.FindByID(ctx, *message.ThreadRootID)
+		if err != nil || root.ConversationID != message.ConversationID {
+			return ErrInvalidParent
+		}
+		if root.ThreadRootID != nil {
+			message.ThreadRootID = root.ThreadRootID
+		}
+	}
+	return nil
 }
 
 func (s *messageService) GetConversationLastMessage(ctx context.Context, conversationID string) (*domain.Message, error) {
 	return s.messageRepo.GetLastMessage(ctx, conversationID)
 }
+
+func (s *messageService) EditMessage(ctx context.Context, messageID, userID, content string) (*domain.Message, error) {
+	if content == "" {
+		return nil, ErrMessageEmpty
+	}
+	if len(content) > 500 {
+		return nil, ErrMessageTooLong
+	}
+
+	message, err := s.messageRepo.FindByID(ctx, messageID)
+	if err != nil {
+		return nil, ErrMessageNotFound
+	}
+	if message.DeletedAt != nil {
+		return nil, ErrMessageDeleted
+	}
+	if message.System {
+		return nil, ErrSystemMessage
+	}
+	// A copy must keep saying what the original said
+	if message.ForwardedFrom != nil {
+		return nil, ErrForwardedMessage
+	}
+	if message.SenderID != userID {
+		return nil, ErrNotMessageSender
+	}
+	// The sender may have left the conversation since sending
+	if err := s.ensureParticipant(ctx, message.ConversationID, userID); err != nil {
+		return nil, err
+	}
+	if time.Since(message.ServerTimestamp) > s.editWindow {
+		return nil, ErrEditWindowExpired
+	}
+	if message.Content == content {
+		return nil, ErrMessageEditNoChange
+	}
+
+	editedAt := time.Now().UTC()
+	if err := s.messageRepo.UpdateContent(ctx, messageID, content, editedAt); err != nil {
+		return nil, err
+	}
+	message.Content = content
+	message.EditedAt = &editedAt
+
+	s.notifyParticipants(ctx, message.ConversationID, domain.EventMessageEdited, message)
+	return message, nil
+}
+
+func (s *messageService) GetMessageRevisions(ctx context.Context, messageID, userID string) ([]*domain.MessageRevision, error) {
+	message, err := s.messageRepo.FindByID(ctx, messageID)
+	if err != nil {
+		return nil, ErrMessageNotFound
+	}
+	if err := s.ensureParticipant(ctx, message.ConversationID, userID); err != nil {
+		return nil, err
+	}
+	return s.messageRepo.GetRevisions(ctx, messageID)
+}
+
+// DeleteMessage deletes a message for the requesting user only, or for every
+// participant. Deleting for everyone leaves a tombstone in history and is
+// limited to the sender, or the owner in group conversations.
+func (s *messageService) DeleteMessage(ctx context.Context, messageID, userID string, scope domain.MessageDeleteScope) error {
+	message, err := s.messageRepo.FindByID(ctx, messageID)
+	if err != nil {
+		return ErrMessageNotFound
+	}
+	if err := s.ensureParticipant(ctx, message.ConversationID, userID); err != nil {
+		return err
+	}
+
+	eventPayload := map[string]interface{}{
+		"message_id":      message.ID,
+		"conversation_id": message.ConversationID,
+		"scope":           scope,
+	}
+
+	switch scope {
+	case domain.DeleteForMe:
+		if err := s.messageRepo.HideForUser(ctx, messageID, userID); err != nil {
+			return err
+		}
+		if _, err := s.bookmarkRepo.Delete(ctx, userID, messageID); err != nil {
+			log.Printf("error removing bookmark of hidden message %s: %v", messageID, err)
+		}
+		// Only the requesting user's other devices need to drop it
+		if err := s.eventService.CreateEvent(ctx, userID, domain.EventMessageDeleted, eventPayload); err != nil {
+			log.Printf("Failed to create %s event for user %s: %v", domain.EventMessageDeleted, userID, err)
+		}
+		return nil
+
+	case domain.DeleteForEveryone:
+		if message.DeletedAt != nil {
+			return ErrMessageDeleted
+		}
+		if message.SenderID != userID {
+			canDelete, err := s.isGroupOwner(ctx, message.ConversationID, userID)
+			if err != nil {
+				return err
+			}
+			if !canDelete {
+				return ErrCannotDeleteMessage
+			}
+		}
+
+		deletedAt := time.Now().UTC()
+		if err := s.messageRepo.MarkDeleted(ctx, messageID, deletedAt); err != nil {
+			return err
+		}
+		eventPayload["deleted_at"] = deletedAt
+		s.notifyParticipants(ctx, message.ConversationID, domain.EventMessageDeleted, eventPayload)
+
+		// A tombstone has nothing left worth pinning, bookmarking or downloading
+		s.deleteAttachments(ctx, []string{messageID})
+		if err := s.bookmarkRepo.DeleteForMessage(ctx, messageID); err != nil {
+			log.Printf("error removing bookmarks of deleted message %s: %v", messageID, err)
+		}
+		unpinned, err := s.pinRepo.Delete(ctx, messageID)
+		if err != nil {
+			log.Printf("error unpinning deleted message %s: %v", messageID, err)
+		} else if unpinned {
+			s.notifyParticipants(ctx, message.ConversationID, domain.EventMessageUnpinned, map[string]interface{}{
+				"message_id":      message.ID,
+				"conversation_id": message.ConversationID,
+				"unpinned_by":     userID,
+			})
+		}
+		return nil
+
+	default:
+		return ErrInvalidDeleteScope
+	}
+}
+
+// deleteAttachments drops the attachments of messages that are gone, along
+// with their blobs unless a forwarded copy still uses them.
+func (s *messageService) deleteAttachments(ctx context.Context, messageIDs []string) {
+	keys, err := s.attachRepo.DeleteForMessages(ctx, messageIDs)
+	if err != nil {
+		log.Printf("error deleting attachments of messages %v: %v", messageIDs, err)
+		return
+	}
+	for _, key := range keys {
+		if err := s.storage.Delete(ctx, key); err != nil {
+			log.Printf("error deleting attachment blob %s: %v", key, err)
+		}
+	}
+}
+
+func (s *messageService) AddReaction(ctx context.Context, messageID, userID, emoji string) error {
+	return s.updateReaction(ctx, messageID, userID, emoji, true)
+}
+
+func (s *messageService) RemoveReaction(ctx context.Context, messageID, userID, emoji string) error {
+	return s.updateReaction(ctx, messageID, userID, emoji, false)
+}
+
+func (s *messageService) updateReaction(ctx context.Context, messageID, userID, emoji string, add bool) error {
+	if emoji == "" || len(emoji) > 32 || strings.ContainsAny(emoji, " \t\n") {
+		return ErrInvalidEmoji
+	}
+
+	message, err := s.messageRepo.FindByID(ctx, messageID)
+	if err != nil {
+		return ErrMessageNotFound
+	}
+	if err := s.ensureParticipant(ctx, message.ConversationID, userID); err != nil {
+		return err
+	}
+	if message.DeletedAt != nil {
+		return ErrMessageDeleted
+	}
+
+	action := "removed"
+	if add {
+		action = "added"
+		err = s.reactionRepo.Add(ctx, messageID, userID, emoji)
+	} else {
+		err = s.reactionRepo.Remove(ctx, messageID, userID, emoji)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Send the full summary so clients can replace theirs without recounting
+	summaries, err := s.reactionRepo.GetForMessages(ctx, []string{messageID})
+	if err != nil {
+		return err
+	}
+	s.notifyParticipants(ctx, message.ConversationID, domain.EventReactionUpdated, map[string]interface{}{
+		"message_id":      messageID,
+		"conversation_id": message.ConversationID,
+		"user_id":         userID,
+		"emoji":           emoji,
+		"action":          action,
+		"reactions":       summaries[messageID],
+	})
+	return nil
+}
+
+// attachDetails fills in the reaction summaries, attachments, pin time and cursor of each message.
+func (s *messageService) attachDetails(ctx context.Context, messages []*domain.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	messageIDs := make([]string, len(messages))
+	for i, msg := range messages {
+		messageIDs[i] = msg.ID
+	}
+	summaries, err := s.reactionRepo.GetForMessages(ctx, messageIDs)
+	if err != nil {
+		return err
+	}
+	attachments, err := s.attachRepo.GetForMessages(ctx, messageIDs)
+	if err != nil {
+		return err
+	}
+	pinnedAt, err := s.pinRepo.GetPinnedAt(ctx, messageIDs)
+	if err != nil {
+		return err
+	}
+	for _, msg := range messages {
+		msg.Reactions = summaries[msg.ID]
+		msg.Attachments = attachments[msg.ID]
+		if at, ok := pinnedAt[msg.ID]; ok {
+			msg.PinnedAt = &at
+		}
+		msg.Cursor = domain.MessageCursor{Timestamp: msg.ServerTimestamp, ID: msg.ID}.String()
+	}
+	return nil
+}
+
+// SearchMessages runs a full-text search over the conversations userID takes
+// part in. The cursor comes from a previous page's NextCursor.
+func (s *messageService) SearchMessages(ctx context.Context, userID string, filter domain.MessageSearchFilter, before *domain.MessageCursor, limit int) (*domain.MessageSearchPage, error) {
+	filter.Query = strings.TrimSpace(filter.Query)
+	if filter.Query == "" {
+		return nil, ErrSearchQueryEmpty
+	}
+	if filter.ConversationID != "" {
+		if err := s.ensureParticipant(ctx, filter.ConversationID, userID); err != nil {
+			return nil, err
+		}
+	}
+	if limit <= 0 || limit > maxPageSize {
+		limit = 20
+	}
+
+	// Fetch one extra hit to learn whether another page follows
+	hits, err := s.messageRepo.Search(ctx, userID, filter, before, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &domain.MessageSearchPage{Results: hits}
+	if len(hits) > limit {
+		page.Results = hits[:limit]
+	}
+
+	messages := make([]*domain.Message, len(page.Results))
+	for i, hit := range page.Results {
+		messages[i] = hit.Message
+	}
+	if err := s.attachDetails(ctx, messages); err != nil {
+		return nil, err
+	}
+	if len(hits) > limit {
+		page.NextCursor = messages[limit-1].Cursor
+	}
+	return page, nil
+}
+
+// PinMessage pins a message to the top of its conversation. Pinning a
+// message that is already pinned changes nothing. In groups only the owner
+// may pin, as there are no group admins.
+func (s *messageService) PinMessage(ctx context.Context, messageID, userID string) (*domain.PinnedMessage, error) {
+	message, err := s.messageRepo.FindByID(ctx, messageID)
+	if err != nil {
+		return nil, ErrMessageNotFound
+	}
+	if message.DeletedAt != nil {
+		return nil, ErrMessageDeleted
+	}
+	if err := s.ensurePinManager(ctx, message.ConversationID, userID); err != nil {
+		return nil, err
+	}
+	count, err := s.pinRepo.CountForConversation(ctx, message.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxPinsPerConversation {
+		return nil, ErrTooManyPins
+	}
+
+	pin := &domain.PinnedMessage{Message: message, PinnedBy: userID, PinnedAt: time.Now().UTC()}
+	created, err := s.pinRepo.Create(ctx, pin)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachDetails(ctx, []*domain.Message{message}); err != nil {
+		return nil, err
+	}
+	if created {
+		s.notifyParticipants(ctx, message.ConversationID, domain.EventMessagePinned, pin)
+	}
+	return pin, nil
+}
+
+// UnpinMessage removes a pin. Unpinning a message that isn't pinned changes nothing.
+func (s *messageService) UnpinMessage(ctx context.Context, messageID, userID string) error {
+	message, err := s.messageRepo.FindByID(ctx, messageID)
+	if err != nil {
+		return ErrMessageNotFound
+	}
+	if err := s.ensurePinManager(ctx, message.ConversationID, userID); err != nil {
+		return err
+	}
+	deleted, err := s.pinRepo.Delete(ctx, messageID)
+	if err != nil {
+		return err
+	}
+	if deleted {
+		s.notifyParticipants(ctx, message.ConversationID, domain.EventMessageUnpinned, map[string]interface{}{
+			"message_id":      message.ID,
+			"conversation_id": message.ConversationID,
+			"unpinned_by":     userID,
+		})
+	}
+	return nil
+}
+
+// GetPinnedMessages lists the conversation's pins, most recently pinned first.
+// Unlike history pages they don't depend on any cursor.
+func (s *messageService) GetPinnedMessages(ctx context.Context, conversationID, userID string) ([]*domain.PinnedMessage, error) {
+	if err := s.ensureParticipant(ctx, conversationID, userID); err != nil {
+		return nil, err
+	}
+	pins, err := s.pinRepo.FindForConversation(ctx, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]*domain.Message, len(pins))
+	for i, pin := range pins {
+		messages[i] = pin.Message
+	}
+	if err := s.attachDetails(ctx, messages); err != nil {
+		return nil, err
+	}
+	return pins, nil
+}
+
+// BookmarkMessage saves a message the user can see to their bookmarks.
+// Bookmarking it again only replaces the note.
+func (s *messageService) BookmarkMessage(ctx context.Context, messageID, userID, note string) (*domain.Bookmark, error) {
+	note = strings.TrimSpace(note)
+	if len(note) > maxBookmarkNoteLength {
+		return nil, ErrBookmarkNoteTooLong
+	}
+	message, err := s.messageRepo.FindByID(ctx, messageID)
+	if err != nil {
+		return nil, ErrMessageNotFound
+	}
+	if message.DeletedAt != nil {
+		return nil, ErrMessageDeleted
+	}
+	if err := s.ensureParticipant(ctx, message.ConversationID, userID); err != nil {
+		return nil, err
+	}
+
+	bookmark := &domain.Bookmark{Message: message, Note: note, CreatedAt: time.Now().UTC()}
+	if err := s.bookmarkRepo.Save(ctx, userID, bookmark); err != nil {
+		return nil, err
+	}
+	if err := s.attachDetails(ctx, []*domain.Message{message}); err != nil {
+		return nil, err
+	}
+	bookmark.Cursor = domain.MessageCursor{Timestamp: bookmark.CreatedAt, ID: message.ID}.String()
+	return bookmark, nil
+}
+
+// RemoveBookmark drops a message from the user's bookmarks. Removing one that
+// isn't bookmarked changes nothing.
+func (s *messageService) RemoveBookmark(ctx context.Context, messageID, userID string) error {
+	_, err := s.bookmarkRepo.Delete(ctx, userID, messageID)
+	return err
+}
+
+// GetBookmarks pages through the user's bookmarks across all conversations,
+// most recently saved first. The cursor comes from a previous page's NextCursor.
+func (s *messageService) GetBookmarks(ctx context.Context, userID string, before *domain.MessageCursor, limit int) (*domain.BookmarkPage, error) {
+	if limit <= 0 || limit > maxPageSize {
+		limit = 20
+	}
+
+	// Fetch one extra bookmark to learn whether another page follows
+	bookmarks, err := s.bookmarkRepo.FindForUser(ctx, userID, before, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &domain.BookmarkPage{Bookmarks: bookmarks}
+	if len(bookmarks) > limit {
+		page.Bookmarks = bookmarks[:limit]
+		page.NextCursor = page.Bookmarks[limit-1].Cursor
+	}
+
+	messages := make([]*domain.Message, len(page.Bookmarks))
+	for i, bookmark := range page.Bookmarks {
+		messages[i] = bookmark.Message
+	}
+	if err := s.attachDetails(ctx, messages); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// ForwardMessages copies messages into other conversations, oldest first. The
+// user must take part in the conversations on both sides. Copies point back to
+// where each message was first sent and share the originals' attachment blobs.
+// Either every copy is created or none is.
+func (s *messageService) ForwardMessages(ctx context.Context, userID string, messageIDs, targetConversationIDs []string) ([]*domain.Message, error) {
+	messageIDs, targetConversationIDs = distinct(messageIDs), distinct(targetConversationIDs)
+	if len(messageIDs) == 0 || len(targetConversationIDs) == 0 {
+		return nil, ErrNothingToForward
+	}
+	if len(messageIDs) > maxForwardMessages || len(targetConversationIDs) > maxForwardTargets {
+		return nil, ErrTooManyForwards
+	}
+
+	originals := make([]*domain.Message, 0, len(messageIDs))
+	for _, messageID := range messageIDs {
+		message, err := s.messageRepo.FindByID(ctx, messageID)
+		if err != nil {
+			return nil, ErrMessageNotFound
+		}
+		if message.DeletedAt != nil {
+			return nil, ErrMessageDeleted
+		}
+		if message.System {
+			return nil, ErrCannotForwardSystem
+		}
+		if err := s.ensureParticipant(ctx, message.ConversationID, userID); err != nil {
+			return nil, err
+		}
+		originals = append(originals, message)
+	}
+	for _, conversationID := range targetConversationIDs {
+		if err := s.ensureParticipant(ctx, conversationID, userID); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(originals, func(i, j int) bool {
+		a, b := originals[i], originals[j]
+		if !a.ServerTimestamp.Equal(b.ServerTimestamp) {
+			return a.ServerTimestamp.Before(b.ServerTimestamp)
+		}
+		return a.ID < b.ID
+	})
+
+	attachments, err := s.attachRepo.GetForMessages(ctx, messageIDs)
+	if err != nil {
+		return nil, err
+	}
+	sender, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	var forwarded []*domain.Message
+	for _, conversationID := range targetConversationIDs {
+		for _, original := range originals {
+			origin := original.ForwardedFrom
+			if origin == nil {
+				origin = &domain.MessageOrigin{
+					MessageID:      original.ID,
+					ConversationID: original.ConversationID,
+					SenderID:       original.SenderID,
+					SenderUsername: original.Sender.Username,
+				}
+			}
+			// Distinct timestamps keep the copies in their original order
+			now = now.Add(time.Microsecond)
+			message := &domain.Message{
+				ID:              uuid.NewString(),
+				ConversationID:  conversationID,
+				SenderID:        userID,
+				Content:         original.Content,
+				ServerTimestamp: now,
+				ForwardedFrom:   origin,
+				Sender:          sender,
+			}
+			message.Cursor = domain.MessageCursor{Timestamp: message.ServerTimestamp, ID: message.ID}.String()
+
+			// New rows pointing at the same stored blobs
+			for _, attachment := range attachments[original.ID] {
+				attachmentCopy := *attachment
+				attachmentCopy.ID = uuid.NewString()
+				attachmentCopy.ConversationID = conversationID
+				attachmentCopy.UploaderID = userID
+				attachmentCopy.MessageID = &message.ID
+				message.Attachments = append(message.Attachments, &attachmentCopy)
+			}
+			forwarded = append(forwarded, message)
+		}
+	}
+	if err := s.messageRepo.CreateForwarded(ctx, forwarded); err != nil {
+		return nil, err
+	}
+
+	for _, message := range forwarded {
+		s.notifyParticipants(ctx, message.ConversationID, domain.EventNewMessage, message)
+	}
+	return forwarded, nil
+}
+
+// distinct returns values without duplicates, keeping their order.
+func distinct(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// SetMessageTTL turns disappearing messages on or off for a conversation and
+// posts a system message announcing the change. Only messages sent from now on
+// are affected; earlier ones keep the expiry they were sent with.
+func (s *messageService) SetMessageTTL(ctx context.Context, conversationID, userID string, ttlSeconds int) (*domain.Message, error) {
+	label, ok := messageTTLLabels[ttlSeconds]
+	if !ok && ttlSeconds != 0 {
+		return nil, ErrInvalidMessageTTL
+	}
+	if err := s.ensureParticipant(ctx, conversationID, userID); err != nil {
+		return nil, err
+	}
+	canChange, err := s.canManageConversation(ctx, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !canChange {
+		return nil, ErrCannotSetMessageTTL
+	}
+	convo, err := s.convoRepo.FindByID(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if convo.MessageTTLSeconds == ttlSeconds {
+		return nil, ErrMessageTTLNoChange
+	}
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.convoRepo.SetMessageTTL(ctx, conversationID, ttlSeconds); err != nil {
+		return nil, err
+	}
+
+	notice := &domain.Message{
+		ID:              uuid.NewString(),
+		ConversationID:  conversationID,
+		SenderID:        userID,
+		Content:         user.Username + " turned off disappearing messages",
+		System:          true,
+		ServerTimestamp: time.Now().UTC(),
+		Sender:          user,
+	}
+	if ttlSeconds != 0 {
+		notice.Content = user.Username + " set messages to disappear after " + label
+	}
+	notice.Cursor = domain.MessageCursor{Timestamp: notice.ServerTimestamp, ID: notice.ID}.String()
+	if err := s.messageRepo.Create(ctx, notice); err != nil {
+		return nil, err
+	}
+
+	s.notifyParticipants(ctx, conversationID, domain.EventMessageTTLUpdated, map[string]interface{}{
+		"conversation_id":     conversationID,
+		"message_ttl_seconds": ttlSeconds,
+		"updated_by":          userID,
+	})
+	s.notifyParticipants(ctx, conversationID, domain.EventNewMessage, notice)
+	return notice, nil
+}
+
+// ExpireMessages hard-deletes messages whose disappearing messages timer has
+// run out, with their attachments and the events holding their content, and
+// tells the participants to drop them.
+func (s *messageService) ExpireMessages(ctx context.Context) error {
+	for {
+		expired, err := s.messageRepo.FindExpired(ctx, time.Now().UTC(), messageExpiryBatch)
+		if err != nil {
+			return err
+		}
+		if len(expired) == 0 {
+			return nil
+		}
+		// Attachments go first, as deleting the messages would cascade to
+		// them; should that then fail, the next run picks the messages up again
+		s.deleteAttachments(ctx, expired)
+		deleted, err := s.messageRepo.DeleteExpired(ctx, expired)
+		if err != nil {
+			return err
+		}
+		for conversationID, messageIDs := range deleted {
+			s.notifyParticipants(ctx, conversationID, domain.EventMessagesExpired, map[string]interface{}{
+				"conversation_id": conversationID,
+				"message_ids":     messageIDs,
+			})
+		}
+		if len(expired) < messageExpiryBatch {
+			return nil
+		}
+	}
+}
+
+func (s *messageService) ensurePinManager(ctx context.Context, conversationID, userID string) error {
+	if err := s.ensureParticipant(ctx, conversationID, userID); err != nil {
+		return err
+	}
+	canPin, err := s.canManageConversation(ctx, conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if !canPin {
+		return ErrCannotPin
+	}
+	return nil
+}
+
+// MarkMessageDelivered records that a device of userID received the message,
+// which also covers every earlier message in the conversation.
+func (s *messageService) MarkMessageDelivered(ctx context.Context, messageID, userID string) error {
+	message, err := s.messageRepo.FindByID(ctx, messageID)
+	if err != nil {
+		return ErrMessageNotFound
+	}
+	if message.SenderID == userID {
+		return nil
+	}
+	if err := s.ensureParticipant(ctx, message.ConversationID, userID); err != nil {
+		return err
+	}
+
+	update := &domain.ReceiptUpdate{ConversationID: message.ConversationID, UserID: userID, Status: domain.ReceiptDelivered, Through: message.ServerTimestamp}
+	senderIDs, err := s.convoRepo.AdvanceReceipt(ctx, message.ConversationID, userID, update.Status, update.Through)
+	if err != nil {
+		return err
+	}
+	notifyReceipt(ctx, s.eventService, senderIDs, update)
+	return nil
+}
+
+// GetMessageReceipts lists each recipient's status for a message; SeenBy is
+// the "seen by" list shown in groups.
+func (s *messageService) GetMessageReceipts(ctx context.Context, messageID, userID string) (*domain.MessageReceipts, error) {
+	message, err := s.messageRepo.FindByID(ctx, messageID)
+	if err != nil {
+		return nil, ErrMessageNotFound
+	}
+	if err := s.ensureParticipant(ctx, message.ConversationID, userID); err != nil {
+		return nil, err
+	}
+	participants, err := s.convoRepo.GetReceipts(ctx, message.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	receipts := &domain.MessageReceipts{Status: domain.ReceiptRead, Recipients: []*domain.MessageReceipt{}, SeenBy: []*domain.User{}}
+	for _, participant := range participants {
+		if participant.User.ID == message.SenderID {
+			continue
+		}
+		status := participant.StatusOf(message.ServerTimestamp)
+		receipts.Recipients = append(receipts.Recipients, &domain.MessageReceipt{User: participant.User, Status: status})
+		if status == domain.ReceiptRead {
+			receipts.SeenBy = append(receipts.SeenBy, participant.User)
+		}
+		receipts.Status = leastReceiptStatus(receipts.Status, status)
+	}
+	return receipts, nil
+}
+
+// attachReceiptStatus sets the receipt status of userID's own messages, all
+// from one conversation: the least advanced status across the other participants.
+func (s *messageService) attachReceiptStatus(ctx context.Context, conversationID, userID string, messages []*domain.Message) error {
+	participants, err := s.convoRepo.GetReceipts(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+	for _, msg := range messages {
+		if msg.SenderID != userID {
+			continue
+		}
+		msg.ReceiptStatus = domain.ReceiptRead
+		for _, participant := range participants {
+			if participant.User.ID != userID {
+				msg.ReceiptStatus = leastReceiptStatus(msg.ReceiptStatus, participant.StatusOf(msg.ServerTimestamp))
+			}
+		}
+	}
+	return nil
+}
+
+var receiptRank = map[domain.ReceiptStatus]int{domain.ReceiptSent: 0, domain.ReceiptDelivered: 1, domain.ReceiptRead: 2}
+
+func leastReceiptStatus(a, b domain.ReceiptStatus) domain.ReceiptStatus {
+	if receiptRank[b] < receiptRank[a] {
+		return b
+	}
+	return a
+}
+
+// checkAttachments resolves the attachments referenced by a new message. Only
+// the sender's own unsent uploads to the same conversation may be attached.
+func (s *messageService) checkAttachments(ctx context.Context, message *domain.Message) ([]string, error) {
+	if len(message.Attachments) > maxAttachmentsPerMessage {
+		return nil, ErrTooManyAttachments
+	}
+	attachmentIDs := make([]string, 0, len(message.Attachments))
+	for i, ref := range message.Attachments {
+		attachment, err := s.attachRepo.FindByID(ctx, ref.ID)
+		if err != nil || attachment.ConversationID != message.ConversationID ||
+			attachment.UploaderID != message.SenderID || attachment.MessageID != nil {
+			return nil, ErrInvalidAttachment
+		}
+		message.Attachments[i] = attachment
+		attachmentIDs = append(attachmentIDs, attachment.ID)
+	}
+	return attachmentIDs, nil
+}
+
+// isGroupOwner reports whether the conversation is a group owned by userID.
+func (s *messageService) isGroupOwner(ctx context.Context, conversationID, userID string) (bool, error) {
+	convo, err := s.convoRepo.FindByID(ctx, conversationID)
+	if err != nil {
+		return false, err
+	}
+	if convo.Type != domain.TypeGroup {
+		return false, nil
+	}
+	group, err := s.groupRepo.FindByID(ctx, conversationID) // Group ID is also the conversation ID
+	if err != nil {
+		return false, err
+	}
+	return group.OwnerID == userID, nil
+}
+
+// canManageConversation reports whether userID may pin messages and change
+// settings of the conversation: either side of a 1:1 chat, but only the
+// owner of a group. Groups have no admin role yet; once they do, admins
+// belong here too.
+func (s *messageService) canManageConversation(ctx context.Context, conversationID, userID string) (bool, error) {
+	convo, err := s.convoRepo.FindByID(ctx, conversationID)
+	if err != nil {
+		return false, err
+	}
+	if convo.Type != domain.TypeGroup {
+		return true, nil
+	}
+	return s.isGroupOwner(ctx, conversationID, userID)
+}
+
+// ensureParticipant returns ErrNotParticipant unless userID belongs to the conversation.
+func (s *messageService) ensureParticipant(ctx context.Context, conversationID, userID string) error {
+	isParticipant, err := s.convoRepo.IsUserInConversation(ctx, conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if !isParticipant {
+		return ErrNotParticipant
+	}
+	return nil
+}
+
+// notifyParticipants creates an event for every participant of the conversation;
+// the Hub delivers it to their live connections.
+func (s *messageService) notifyParticipants(ctx context.Context, conversationID string, eventType domain.EventType, payload interface{}) {
+	participantIDs, err := s.convoRepo.GetParticipantIDs(ctx, conversationID)
+	if err != nil {
+		log.Printf("error getting participants for convo %s: %v", conversationID, err)
+		return
+	}
+	for _, participantID := range participantIDs {
+		if err := s.eventService.CreateEvent(ctx, participantID, eventType, payload); err != nil {
+			log.Printf("Failed to create %s event for user %s: %v", eventType, participantID, err)
+		}
+	}
+}
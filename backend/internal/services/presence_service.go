@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"real-time-chat/internal/domain"
+	"real-time-chat/internal/usecase"
+	"time"
+)
+
+var ErrInvalidPresenceStatus = errors.New("presence status must be online or away")
+
+const (
+	// Connections lapse unless refreshed within presenceTTL. The hub refreshes
+	// its connections every 30s, so a live one survives two missed refreshes.
+	presenceTTL = 90 * time.Second
+	// Users handled per ExpireStale call
+	presenceExpiryBatch = 100
+)
+
+type presenceService struct {
+	presenceStore  domain.PresenceStore
+	userRepo       domain.UserRepository
+	friendshipRepo domain.FriendshipRepository
+	eventService   usecase.EventUseCase
+}
+
+func NewPresenceService(presenceStore domain.PresenceStore, userRepo domain.UserRepository, friendshipRepo domain.FriendshipRepository, eventService usecase.EventUseCase) usecase.PresenceUseCase {
+	return &presenceService{presenceStore: presenceStore, userRepo: userRepo, friendshipRepo: friendshipRepo, eventService: eventService}
+}
+
+func (s *presenceService) Heartbeat(ctx context.Context, userID, connectionID string, status domain.PresenceStatus) error {
+	if status != domain.PresenceOnline && status != domain.PresenceAway {
+		return ErrInvalidPresenceStatus
+	}
+	before, after, err := s.presenceStore.SetConnection(ctx, userID, connectionID, status, presenceTTL)
+	if err != nil {
+		return err
+	}
+	if before != after {
+		s.notifyFriends(ctx, &domain.Presence{UserID: userID, Status: after})
+	}
+	return nil
+}
+
+// KeepAlive stops a live connection from lapsing. Unlike Heartbeat it never
+// re-adds a connection that was disconnected in the meantime.
+func (s *presenceService) KeepAlive(ctx context.Context, userID, connectionID string) error {
+	_, err := s.presenceStore.RefreshConnection(ctx, userID, connectionID, presenceTTL)
+	return err
+}
+
+func (s *presenceService) Disconnect(ctx context.Context, userID, connectionID string) error {
+	before, after, err := s.presenceStore.RemoveConnection(ctx, userID, connectionID)
+	if err != nil {
+		return err
+	}
+	if before == after {
+		return nil
+	}
+	if after == domain.PresenceOffline {
+		return s.wentOffline(ctx, userID)
+	}
+	s.notifyFriends(ctx, &domain.Presence{UserID: userID, Status: after})
+	return nil
+}
+
+// ExpireStale takes users whose connections lapsed without a disconnect,
+// e.g. because the node holding them crashed, and marks them offline.
+func (s *presenceService) ExpireStale(ctx context.Context) error {
+	userIDs, err := s.presenceStore.ClaimExpired(ctx, time.Now(), presenceExpiryBatch)
+	if err != nil || len(userIDs) == 0 {
+		return err
+	}
+	statuses, err := s.presenceStore.GetStatuses(ctx, userIDs)
+	if err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		if statuses[userID] != domain.PresenceOffline {
+			continue // Reconnected in the meantime
+		}
+		if err := s.wentOffline(ctx, userID); err != nil {
+			log.Printf("error expiring presence of user %s: %v", userID, err)
+		}
+	}
+	return nil
+}
+
+func (s *presenceService) wentOffline(ctx context.Context, userID string) error {
+	now := time.Now()
+	if err := s.userRepo.UpdateLastSeen(ctx, userID, now); err != nil {
+		return err
+	}
+	s.notifyFriends(ctx, &domain.Presence{UserID: userID, Status: domain.PresenceOffline, LastSeenAt: &now})
+	return nil
+}
+
+func (s *presenceService) GetFriendsPresence(ctx context.Context, userID string) ([]*domain.Presence, error) {
+	friends, err := s.friendshipRepo.GetFriends(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	friendIDs := make([]string, len(friends))
+	for i, friend := range friends {
+		friendIDs[i] = friend.ID
+	}
+	presences, err := s.userRepo.FindPresence(ctx, friendIDs)
+	if err != nil {
+		return nil, err
+	}
+	statuses, err := s.presenceStore.GetStatuses(ctx, friendIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, presence := range presences {
+		if presence.Hidden {
+			presence.LastSeenAt = nil
+			continue
+		}
+		presence.Status = statuses[presence.UserID]
+	}
+	return presences, nil
+}
+
+// SetPresenceHidden changes the user's privacy setting. Friends are sent the
+// presence they can now see: offline when hidden, the real one otherwise.
+func (s *presenceService) SetPresenceHidden(ctx context.Context, userID string, hidden bool) error {
+	if err := s.userRepo.UpdatePresenceVisibility(ctx, userID, hidden); err != nil {
+		return err
+	}
+	presence := &domain.Presence{UserID: userID, Status: domain.PresenceOffline}
+	if !hidden {
+		presences, err := s.userRepo.FindPresence(ctx, []string{userID})
+		if err != nil {
+			return err
+		}
+		statuses, err := s.presenceStore.GetStatuses(ctx, []string{userID})
+		if err != nil {
+			return err
+		}
+		if len(presences) == 1 {
+			presence.LastSeenAt = presences[0].LastSeenAt
+		}
+		presence.Status = statuses[userID]
+	}
+	sendToFriends(ctx, s.friendshipRepo, s.eventService, userID, domain.EventPresenceUpdated, presence)
+	return nil
+}
+
+// notifyFriends sends a presence change to the user's friends, unless the
+// user hides their presence.
+func (s *presenceService) notifyFriends(ctx context.Context, presence *domain.Presence) {
+	user, err := s.userRepo.FindByID(ctx, presence.UserID)
+	if err != nil {
+		log.Printf("error loading user %s for presence update: %v", presence.UserID, err)
+		return
+	}
+	if user.HidePresence {
+		return
+	}
+	sendToFriends(ctx, s.friendshipRepo, s.eventService, presence.UserID, domain.EventPresenceUpdated, presence)
+}
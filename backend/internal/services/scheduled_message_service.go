@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"real-time-chat/internal/domain"
+	"real-time-chat/internal/usecase"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrScheduledMessageNotFound = errors.New("scheduled message not found")
+	ErrScheduledMessageSending  = errors.New("scheduled message is already being sent")
+	ErrScheduleInPast           = errors.New("scheduled send time must be in the future")
+)
+
+const (
+	scheduledClaimLease = time.Minute // How long a node has to send a claimed message before another may retry it
+	scheduledClaimBatch = 50
+)
+
+type scheduledMessageService struct {
+	scheduledRepo domain.ScheduledMessageRepository
+	convoRepo     domain.ConversationRepository
+	eventService  usecase.EventUseCase // Tells the sender how their scheduled send went
+}
+
+func NewScheduledMessageService(scheduledRepo domain.ScheduledMessageRepository, convoRepo domain.ConversationRepository, eventService usecase.EventUseCase) usecase.ScheduledMessageUseCase {
+	return &scheduledMessageService{scheduledRepo: scheduledRepo, convoRepo: convoRepo, eventService: eventService}
+}
+
+// ScheduleMessage stores a message to be sent into a conversation the sender
+// belongs to once SendAt arrives.
+func (s *scheduledMessageService) ScheduleMessage(ctx context.Context, scheduled *domain.ScheduledMessage) (*domain.ScheduledMessage, error) {
+	now := time.Now().UTC()
+	if err := validateScheduledMessage(scheduled.Content, scheduled.SendAt, now); err != nil {
+		return nil, err
+	}
+	isParticipant, err := s.convoRepo.IsUserInConversation(ctx, scheduled.ConversationID, scheduled.SenderID)
+	if err != nil {
+		return nil, err
+	}
+	if !isParticipant {
+		return nil, ErrNotParticipant
+	}
+
+	scheduled.ID = uuid.NewString()
+	scheduled.SendAt = scheduled.SendAt.UTC()
+	scheduled.CreatedAt = now
+	if err := s.scheduledRepo.Create(ctx, scheduled); err != nil {
+		return nil, err
+	}
+	return scheduled, nil
+}
+
+func (s *scheduledMessageService) GetScheduledMessages(ctx context.Context, userID string) ([]*domain.ScheduledMessage, error) {
+	return s.scheduledRepo.FindForUser(ctx, userID)
+}
+
+// UpdateScheduledMessage changes the content and send time of a message that
+// hasn't started sending yet.
+func (s *scheduledMessageService) UpdateScheduledMessage(ctx context.Context, id, userID, content string, sendAt time.Time) (*domain.ScheduledMessage, error) {
+	now := time.Now().UTC()
+	if err := validateScheduledMessage(content, sendAt, now); err != nil {
+		return nil, err
+	}
+	scheduled, err := s.findOwn(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	scheduled.Content = content
+	scheduled.SendAt = sendAt.UTC()
+	updated, err := s.scheduledRepo.Update(ctx, scheduled, now)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, ErrScheduledMessageSending
+	}
+	return scheduled, nil
+}
+
+// CancelScheduledMessage drops a message that hasn't started sending yet.
+func (s *scheduledMessageService) CancelScheduledMessage(ctx context.Context, id, userID string) error {
+	if _, err := s.findOwn(ctx, id, userID); err != nil {
+		return err
+	}
+	cancelled, err := s.scheduledRepo.Cancel(ctx, id, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		return ErrScheduledMessageSending
+	}
+	return nil
+}
+
+// ClaimDue returns the messages due now for the caller to send, then pass to
+// CompleteSend. Other nodes won't be handed the same messages meanwhile.
+func (s *scheduledMessageService) ClaimDue(ctx context.Context) ([]*domain.ScheduledMessage, error) {
+	now := time.Now().UTC()
+	return s.scheduledRepo.ClaimDue(ctx, now, now.Add(scheduledClaimLease), scheduledClaimBatch)
+}
+
+// CompleteSend records the outcome of sending a claimed message. Messages
+// that can never be sent, e.g. because the sender has left the conversation,
+// are dropped and the sender is told why; other failures are retried once
+// the claim lapses.
+func (s *scheduledMessageService) CompleteSend(ctx context.Context, scheduled *domain.ScheduledMessage, sent *domain.Message, sendErr error) error {
+	if sendErr != nil && !isUndeliverable(sendErr) {
+		log.Printf("error sending scheduled message %s, will retry: %v", scheduled.ID, sendErr)
+		return nil
+	}
+	if err := s.scheduledRepo.Complete(ctx, scheduled.ID); err != nil {
+		return err
+	}
+
+	eventType := domain.EventScheduledMessageSent
+	payload := map[string]interface{}{
+		"scheduled_message_id": scheduled.ID,
+		"conversation_id":      scheduled.ConversationID,
+	}
+	if sendErr != nil {
+		eventType = domain.EventScheduledMessageFailed
+		payload["scheduled_message"] = scheduled
+		payload["reason"] = sendErr.Error()
+	} else {
+		payload["message_id"] = sent.ID
+	}
+	if err := s.eventService.CreateEvent(ctx, scheduled.SenderID, eventType, payload); err != nil {
+		log.Printf("Failed to create %s event for user %s: %v", eventType, scheduled.SenderID, err)
+	}
+	return nil
+}
+
+func (s *scheduledMessageService) findOwn(ctx context.Context, id, userID string) (*domain.ScheduledMessage, error) {
+	scheduled, err := s.scheduledRepo.FindByID(ctx, id)
+	if err != nil || scheduled.SenderID != userID {
+		return nil, ErrScheduledMessageNotFound
+	}
+	return scheduled, nil
+}
+
+func validateScheduledMessage(content string, sendAt, now time.Time) error {
+	if strings.TrimSpace(content) == "" {
+		return ErrMessageEmpty
+	}
+	if len(content) > 500 {
+		return ErrMessageTooLong
+	}
+	if !sendAt.After(now) {
+		return ErrScheduleInPast
+	}
+	return nil
+}
+
+// isUndeliverable reports whether SaveMessage rejected a message outright,
+// so retrying would fail the same way.
+func isUndeliverable(err error) bool {
+	return errors.Is(err, ErrNotParticipant) || errors.Is(err, ErrMessageTooLong)
+}
@@ -1,17 +1,25 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"path"
 	"real-time-chat/internal/config"
 	"real-time-chat/internal/domain"
 	"real-time-chat/internal/usecase"
 	"real-time-chat/internal/utils"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -27,6 +35,20 @@ var (
 	ErrAlreadyVerified       = errors.New("email already verified")
 	ErrUserAlreadyExists     = errors.New("user with this email or username already exists")
 	ErrProfilePictureInvalid = errors.New("invalid profile picture URL")
+	ErrProfilePictureFormat  = errors.New("profile picture must be a PNG, JPEG, GIF or WebP image")
+	ErrProfilePictureTooBig  = errors.New("profile picture exceeds 5 MB or 24 megapixels")
+	ErrStatusTooLong         = errors.New("status text is limited to 100 characters and emoji to 16")
+	ErrStatusExpiryInPast    = errors.New("status expiry must be in the future")
+)
+
+const (
+	// Profile pictures are linked as profilePictureURLPrefix + storage key;
+	// that route redirects to a signed URL for the blob.
+	profilePictureURLPrefix = "/uploads/"
+	profilePictureKeyPrefix = "avatars/"
+	maxProfilePictureBytes  = 5 * 1024 * 1024
+	maxStatusTextLength     = 100 // Characters
+	maxStatusEmojiLength    = 16  // Code points; allows joined emoji sequences
 )
 
 type UserService struct {
@@ -34,16 +56,24 @@ type UserService struct {
 }
 
 type userService struct {
-	userRepo     domain.UserRepository
-	TokenService usecase.TokenUseCase
-	emailSender  utils.EmailSender
+	userRepo       domain.UserRepository
+	friendshipRepo domain.FriendshipRepository // Status changes go out to friends
+	TokenService   usecase.TokenUseCase
+	emailSender    utils.EmailSender
+	eventService   usecase.EventUseCase
+	storage        domain.BlobStorage // Holds profile pictures
+	urlExpiry      time.Duration      // Lifetime of signed profile picture URLs
 }
 
-func NewUserService(userRepo domain.UserRepository, tokenService usecase.TokenUseCase, emailSender utils.EmailSender) usecase.UserUseCase {
+func NewUserService(userRepo domain.UserRepository, friendshipRepo domain.FriendshipRepository, tokenService usecase.TokenUseCase, emailSender utils.EmailSender, eventService usecase.EventUseCase, storage domain.BlobStorage, urlExpiry time.Duration) usecase.UserUseCase {
 	return &userService{
-		userRepo:     userRepo,
-		TokenService: tokenService,
-		emailSender:  emailSender,
+		userRepo:       userRepo,
+		friendshipRepo: friendshipRepo,
+		TokenService:   tokenService,
+		emailSender:    emailSender,
+		eventService:   eventService,
+		storage:        storage,
+		urlExpiry:      urlExpiry,
 	}
 }
 
@@ -166,21 +196,93 @@ func (s *userService) ResetPassword(ctx context.Context, email, otp, newPassword
 	return nil
 }
 
-func (s *userService) UploadProfilePicture(ctx context.Context, userID, filename string) (string, error) {
-	user, err := s.userRepo.FindByID(ctx, userID)
+// UploadProfilePicture stores center-cropped thumbnails of the image in each of
+// utils.AvatarSizes, under avatars/<id>/<size><ext>, and links the largest.
+// The files of the picture it replaces are deleted.
+func (s *userService) UploadProfilePicture(ctx context.Context, userID string, content io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(content, maxProfilePictureBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxProfilePictureBytes {
+		return "", ErrProfilePictureTooBig
+	}
+	thumbnails, err := utils.MakeAvatarThumbnails(data)
+	if errors.Is(err, utils.ErrUnsupportedImage) {
+		return "", ErrProfilePictureFormat
+	}
+	if errors.Is(err, utils.ErrImageTooLarge) {
+		return "", ErrProfilePictureTooBig
+	}
 	if err != nil {
-		return "", ErrUserNotFound
+		return "", err
 	}
 
-	// Construct public URL
-	publicURL := "/uploads/" + filename // This assumes /uploads is served statically
+	dir := profilePictureKeyPrefix + uuid.NewString() + "/"
+	var key string
+	for _, thumbnail := range thumbnails {
+		key = dir + strconv.Itoa(thumbnail.Size) + thumbnail.Ext
+		if err := s.storage.Put(ctx, key, thumbnail.ContentType, bytes.NewReader(thumbnail.Data), int64(len(thumbnail.Data))); err != nil {
+			s.deleteProfilePicture(ctx, profilePictureURLPrefix+key)
+			return "", err
+		}
+	}
 
-	if err := s.userRepo.UpdateProfilePicture(ctx, user.ID, publicURL); err != nil {
+	publicURL := profilePictureURLPrefix + key // Thumbnails are ordered by size, so this is the largest
+	previousURL, err := s.userRepo.UpdateProfilePicture(ctx, userID, publicURL)
+	if err != nil {
+		s.deleteProfilePicture(ctx, publicURL) // Clean up uploaded files if DB update fails
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrUserNotFound
+		}
 		return "", err
 	}
+	s.deleteProfilePicture(ctx, previousURL)
 	return publicURL, nil
 }
 
+// ProfilePictureURL returns a signed URL for the profile picture stored under
+// key, or for its thumbnail of the given size when size is non-zero. Keys
+// without a directory predate avatars/ and are accepted too; anything else
+// (e.g. attachments) is refused, since profile pictures are public. Keys must
+// be clean, or "avatars/../attachments/..." would pass the prefix check.
+func (s *userService) ProfilePictureURL(ctx context.Context, key string, size int) (string, error) {
+	if !domain.IsCleanBlobKey(key) {
+		return "", ErrProfilePictureInvalid
+	}
+	if !strings.HasPrefix(key, profilePictureKeyPrefix) && strings.Contains(key, "/") {
+		return "", ErrProfilePictureInvalid
+	}
+	if dir, file := path.Split(key); size != 0 && strings.Count(key, "/") == 2 {
+		if !slices.Contains(utils.AvatarSizes, size) {
+			return "", ErrProfilePictureInvalid
+		}
+		key = dir + strconv.Itoa(size) + path.Ext(file)
+	}
+	return s.storage.SignedURL(ctx, key, s.urlExpiry)
+}
+
+// deleteProfilePicture removes the stored files behind a profile picture URL:
+// every thumbnail for avatars/<id>/ pictures, or the single file of older ones.
+func (s *userService) deleteProfilePicture(ctx context.Context, url string) {
+	key, ok := strings.CutPrefix(url, profilePictureURLPrefix)
+	if !ok || key == "" {
+		return
+	}
+	keys := []string{key}
+	if dir, file := path.Split(key); strings.HasPrefix(key, profilePictureKeyPrefix) && strings.Count(key, "/") == 2 {
+		keys = keys[:0]
+		for _, size := range utils.AvatarSizes {
+			keys = append(keys, dir+strconv.Itoa(size)+path.Ext(file))
+		}
+	}
+	for _, k := range keys {
+		if err := s.storage.Delete(ctx, k); err != nil {
+			log.Printf("Failed to delete old profile picture %s: %v", k, err)
+		}
+	}
+}
+
 // tokenService implements TokenUseCase for JWT and OTP management
 type tokenService struct {
 	userRepo        domain.UserRepository // Added to resolve user for claims during token creation
@@ -319,3 +421,31 @@ func (s *tokenService) GetOTP(ctx context.Context, email string) (string, error)
 func (s *tokenService) DeleteOTP(ctx context.Context, email string) error {
 	return s.tokenRepo.DeleteOTP(ctx, email)
 }
+
+// UpdateStatus replaces the user's custom status and do-not-disturb setting
+// and tells their friends. Clearing the text and emoji also clears the expiry.
+func (s *userService) UpdateStatus(ctx context.Context, userID string, status *domain.UserStatus) (*domain.UserStatus, error) {
+	status.Text = strings.TrimSpace(status.Text)
+	status.Emoji = strings.TrimSpace(status.Emoji)
+	if utf8.RuneCountInString(status.Text) > maxStatusTextLength || utf8.RuneCountInString(status.Emoji) > maxStatusEmojiLength {
+		return nil, ErrStatusTooLong
+	}
+	now := time.Now()
+	if status.Text == "" && status.Emoji == "" {
+		status.ExpiresAt = nil
+	} else if status.ExpiresAt != nil && !status.ExpiresAt.After(now) {
+		return nil, ErrStatusExpiryInPast
+	}
+	if status.DNDSchedule != nil {
+		if err := status.DNDSchedule.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.userRepo.UpdateStatus(ctx, userID, status); err != nil {
+		return nil, err
+	}
+	status.Resolve(now)
+	sendToFriends(ctx, s.friendshipRepo, s.eventService, userID, domain.EventStatusUpdated, &domain.UserStatusUpdate{UserID: userID, Status: status})
+	return status, nil
+}
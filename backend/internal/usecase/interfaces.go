@@ -2,6 +2,7 @@ package usecase
 
 import (
 	"context"
+	"io"
 	"real-time-chat/internal/config"
 	"real-time-chat/internal/domain"
 	"time"
@@ -19,7 +20,9 @@ type UserUseCase interface {
 	VerifyEmail(ctx context.Context, token string) error
 	RequestPasswordReset(ctx context.Context, email string) error
 	ResetPassword(ctx context.Context, email, otp, newPassword string) error
-	UploadProfilePicture(ctx context.Context, userID, filename string) (string, error)
+	UploadProfilePicture(ctx context.Context, userID string, content io.Reader) (string, error)
+	ProfilePictureURL(ctx context.Context, key string, size int) (string, error)
+	UpdateStatus(ctx context.Context, userID string, status *domain.UserStatus) (*domain.UserStatus, error)
 }
 
 type TokenUseCase interface {
@@ -35,8 +38,45 @@ type TokenUseCase interface {
 
 type MessageUseCase interface {
 	SaveMessage(ctx context.Context, message *domain.Message) (*domain.Message, error)
-	GetMessagesForConversation(ctx context.Context, conversationID, userID string, before time.Time, limit int) ([]*domain.Message, error)
+	GetMessagesForConversation(ctx context.Context, conversationID, userID string, before, after *domain.MessageCursor, limit int) ([]*domain.Message, error)
 	GetConversationLastMessage(ctx context.Context, conversationID string) (*domain.Message, error)
+	GetThreadReplies(ctx context.Context, rootMessageID, userID string, before, after *domain.MessageCursor, limit int) ([]*domain.Message, error)
+	GetMessagesAround(ctx context.Context, messageID, userID string, limit int) (*domain.MessageWindow, error)
+	EditMessage(ctx context.Context, messageID, userID, content string) (*domain.Message, error)
+	GetMessageRevisions(ctx context.Context, messageID, userID string) ([]*domain.MessageRevision, error)
+	DeleteMessage(ctx context.Context, messageID, userID string, scope domain.MessageDeleteScope) error
+	AddReaction(ctx context.Context, messageID, userID, emoji string) error
+	RemoveReaction(ctx context.Context, messageID, userID, emoji string) error
+	PinMessage(ctx context.Context, messageID, userID string) (*domain.PinnedMessage, error)
+	UnpinMessage(ctx context.Context, messageID, userID string) error
+	GetPinnedMessages(ctx context.Context, conversationID, userID string) ([]*domain.PinnedMessage, error)
+	ForwardMessages(ctx context.Context, userID string, messageIDs, targetConversationIDs []string) ([]*domain.Message, error)
+	SetMessageTTL(ctx context.Context, conversationID, userID string, ttlSeconds int) (*domain.Message, error)
+	ExpireMessages(ctx context.Context) error
+	BookmarkMessage(ctx context.Context, messageID, userID, note string) (*domain.Bookmark, error)
+	RemoveBookmark(ctx context.Context, messageID, userID string) error
+	GetBookmarks(ctx context.Context, userID string, before *domain.MessageCursor, limit int) (*domain.BookmarkPage, error)
+	MarkMessageDelivered(ctx context.Context, messageID, userID string) error
+	GetMessageReceipts(ctx context.Context, messageID, userID string) (*domain.MessageReceipts, error)
+	SearchMessages(ctx context.Context, userID string, filter domain.MessageSearchFilter, before *domain.MessageCursor, limit int) (*domain.MessageSearchPage, error)
+}
+
+// ScheduledMessageUseCase keeps messages to be sent later. Whatever sends
+// messages live claims the due ones with ClaimDue, sends them the same way
+// and reports back through CompleteSend.
+type ScheduledMessageUseCase interface {
+	ScheduleMessage(ctx context.Context, scheduled *domain.ScheduledMessage) (*domain.ScheduledMessage, error)
+	GetScheduledMessages(ctx context.Context, userID string) ([]*domain.ScheduledMessage, error)
+	UpdateScheduledMessage(ctx context.Context, id, userID, content string, sendAt time.Time) (*domain.ScheduledMessage, error)
+	CancelScheduledMessage(ctx context.Context, id, userID string) error
+	ClaimDue(ctx context.Context) ([]*domain.ScheduledMessage, error)
+	CompleteSend(ctx context.Context, scheduled *domain.ScheduledMessage, sent *domain.Message, sendErr error) error
+}
+
+type AttachmentUseCase interface {
+	Upload(ctx context.Context, conversationID, userID, fileName string, content io.Reader) (*domain.Attachment, error)
+	Open(ctx context.Context, attachmentID, userID string) (*domain.Attachment, io.ReadCloser, error)
+	SignedURL(ctx context.Context, attachmentID, userID string) (string, time.Time, error)
 }
 
 type ConversationUseCase interface {
@@ -60,6 +100,18 @@ type FriendshipUseCase interface {
 	GetFriends(ctx context.Context, userID string) ([]*domain.User, error)
 }
 
+// PresenceUseCase tracks which users are connected. Transports report each
+// connection through Heartbeat (on connect and when its status changes),
+// KeepAlive (periodically) and Disconnect.
+type PresenceUseCase interface {
+	Heartbeat(ctx context.Context, userID, connectionID string, status domain.PresenceStatus) error
+	KeepAlive(ctx context.Context, userID, connectionID string) error
+	Disconnect(ctx context.Context, userID, connectionID string) error
+	ExpireStale(ctx context.Context) error
+	GetFriendsPresence(ctx context.Context, userID string) ([]*domain.Presence, error)
+	SetPresenceHidden(ctx context.Context, userID string, hidden bool) error
+}
+
 type GroupUseCase interface {
 	CreateGroup(ctx context.Context, ownerID, name, slug string, initialMembers []string) (*domain.Group, error)
 	GetGroupDetails(ctx context.Context, groupID string) (*domain.Group, []*domain.User, error)
@@ -76,7 +128,13 @@ type GameUseCase interface {
 	RespondToGameInvite(ctx context.Context, gameID, userID string, accept bool) (*domain.Game, error)
 }
 
+// EventListener is invoked after an event has been persisted, so transports
+// (WebSocket, long polling, ...) can deliver it to the recipient.
+type EventListener func(event *domain.Event)
+
 type EventUseCase interface {
 	CreateEvent(ctx context.Context, userID string, eventType domain.EventType, payload interface{}) error
-	GetEventsForUser(ctx context.Context, userID string, sinceEventID string, limit int) ([]*domain.Event, error)
+	GetEventsForUser(ctx context.Context, userID string, sinceSeq int64, limit int) ([]*domain.Event, error)
+	WaitForEvents(ctx context.Context, userID string, sinceSeq int64, limit int) ([]*domain.Event, error)
+	AddListener(listener EventListener)
 }
@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	_ "image/gif" // Register decoders for image.Decode
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// AvatarSizes are the edge lengths, in pixels, of the square thumbnails made
+// for each profile picture, smallest first.
+var AvatarSizes = []int{32, 64, 256}
+
+var (
+	ErrUnsupportedImage = errors.New("file must be a PNG, JPEG, GIF or WebP image")
+	ErrImageTooLarge    = errors.New("image dimensions are too large")
+)
+
+const maxImagePixels = 24_000_000 // Guards against decompression bombs
+
+var supportedImageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+type Thumbnail struct {
+	Size        int
+	Data        []byte
+	ContentType string
+	Ext         string
+}
+
+// MakeAvatarThumbnails decodes an uploaded image, whatever its claimed name,
+// and returns a center-cropped square thumbnail for each of AvatarSizes.
+// Re-encoding drops EXIF and other metadata, so the EXIF orientation is
+// applied first to keep photos upright.
+func MakeAvatarThumbnails(data []byte) ([]*Thumbnail, error) {
+	if !supportedImageTypes[http.DetectContentType(data)] {
+		return nil, ErrUnsupportedImage
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	orientation := 1
+	if format == "jpeg" {
+		orientation = jpegOrientation(data)
+	}
+
+	// Cropping the centered square commutes with the EXIF rotations and flips,
+	// so orient the small thumbnails instead of the full image.
+	bounds := src.Bounds()
+	edge := min(bounds.Dx(), bounds.Dy())
+	crop := image.Rect(0, 0, edge, edge).Add(bounds.Min).Add(image.Pt((bounds.Dx()-edge)/2, (bounds.Dy()-edge)/2))
+
+	thumbnails := make([]*Thumbnail, 0, len(AvatarSizes))
+	for _, size := range AvatarSizes {
+		dst := image.NewRGBA(image.Rect(0, 0, size, size))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Src, nil)
+		dst = orient(dst, orientation)
+
+		// Keep transparency where there is any; otherwise JPEG is far smaller
+		var buf bytes.Buffer
+		thumbnail := &Thumbnail{Size: size}
+		if dst.Opaque() {
+			thumbnail.ContentType, thumbnail.Ext = "image/jpeg", ".jpg"
+			err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+		} else {
+			thumbnail.ContentType, thumbnail.Ext = "image/png", ".png"
+			err = png.Encode(&buf, dst)
+		}
+		if err != nil {
+			return nil, err
+		}
+		thumbnail.Data = buf.Bytes()
+		thumbnails = append(thumbnails, thumbnail)
+	}
+	return thumbnails, nil
+}
+
+// orient applies an EXIF orientation (1-8) to a square image.
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	n := img.Bounds().Dx()
+	out := image.NewRGBA(img.Bounds())
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			dx, dy := x, y
+			switch orientation {
+			case 2: // Mirrored horizontally
+				dx = n - 1 - x
+			case 3: // Rotated 180°
+				dx, dy = n-1-x, n-1-y
+			case 4: // Mirrored vertically
+				dy = n - 1 - y
+			case 5: // Transposed
+				dx, dy = y, x
+			case 6: // Needs a 90° clockwise turn
+				dx, dy = n-1-y, x
+			case 7: // Transversed
+				dx, dy = n-1-y, n-1-x
+			case 8: // Needs a 90° counter-clockwise turn
+				dx, dy = y, n-1-x
+			}
+			out.SetRGBA(dx, dy, img.RGBAAt(x, y))
+		}
+	}
+	return out
+}
+
+// jpegOrientation reads the orientation tag from a JPEG's EXIF block,
+// returning 1 (upright) when there is none.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF; {
+		marker := data[i+1]
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if marker == 0xDA || length < 2 || i+2+length > len(data) {
+			break // Image data starts; no more metadata segments
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation finds tag 0x0112 in IFD0 of a TIFF-structured EXIF payload.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for e := 0; e < entries; e++ {
+		entry := ifd + 2 + e*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 1
+}
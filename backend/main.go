@@ -6,16 +6,18 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"real-time-chat/internal/adapters/postgres"
 	"real-time-chat/internal/adapters/redis"
+	"real-time-chat/internal/adapters/storage"
 	"real-time-chat/internal/config"
 	"real-time-chat/internal/delivery/http_delivery"
 	"real-time-chat/internal/delivery/ws_delivery"
+	"real-time-chat/internal/domain"
 	"real-time-chat/internal/services"
 	"real-time-chat/internal/utils"
 	"syscall"
 	"time"
+	_ "time/tzdata" // Do-not-disturb schedules use IANA time zones; don't depend on the host having them
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -52,35 +54,51 @@ func main() {
 	groupRepo := postgres.NewPostgresGroupRepository(dbPool)
 	gameRepo := postgres.NewPostgresGameRepository(dbPool)
 	eventRepo := postgres.NewPostgresEventRepository(dbPool) // New event repository
+	reactionRepo := postgres.NewPostgresReactionRepository(dbPool)
+	attachmentRepo := postgres.NewPostgresAttachmentRepository(dbPool)
+	pinRepo := postgres.NewPostgresPinRepository(dbPool)
+	bookmarkRepo := postgres.NewPostgresBookmarkRepository(dbPool)
+	scheduledRepo := postgres.NewPostgresScheduledMessageRepository(dbPool)
+	presenceStore := redis.NewRedisPresenceStore(redisClient)
+	blobStorage, localStorage := newBlobStorage(cfg)
+	eventNotifier := postgres.NewPostgresEventNotifier(dbPool)
+	notifierCtx, stopNotifier := context.WithCancel(context.Background())
+	defer stopNotifier()
+	go eventNotifier.Listen(notifierCtx)
 
 	// Services
 	tokenService := services.NewTokenService(userRepo, tokenRepo, cfg.JWTSecret, time.Hour*8, time.Hour*24*7, time.Minute*30) // OTP expiry 30 mins
-	eventService := services.NewEventService(eventRepo, userRepo)                                                             // New event service
-	userService := services.NewUserService(userRepo, tokenService, emailSender)
-	convoService := services.NewConversationService(convoRepo, userRepo, groupRepo)
-	messageService := services.NewMessageService(messageRepo, convoRepo, userRepo)
+	eventService := services.NewEventService(eventRepo, userRepo, eventNotifier)                                              // New event service
+	userService := services.NewUserService(userRepo, friendshipRepo, tokenService, emailSender, eventService, blobStorage, cfg.SignedURLExpiry)
+	convoService := services.NewConversationService(convoRepo, userRepo, groupRepo, bookmarkRepo, eventService)
+	messageService := services.NewMessageService(messageRepo, convoRepo, userRepo, groupRepo, reactionRepo, attachmentRepo, pinRepo, bookmarkRepo, blobStorage, eventService, cfg.MessageEditWindow)
+	attachmentService := services.NewAttachmentService(attachmentRepo, convoRepo, blobStorage, cfg.MaxAttachmentSize, cfg.SignedURLExpiry)
 	friendshipService := services.NewFriendshipService(friendshipRepo, userRepo, convoService, eventService) // Pass eventService
 	groupService := services.NewGroupService(groupRepo, userRepo, convoService, eventService)                // Pass eventService
 	gameService := services.NewGameService(gameRepo, userRepo, convoService, eventService)                   // Pass eventService
+	presenceService := services.NewPresenceService(presenceStore, userRepo, friendshipRepo, eventService)
+	scheduledService := services.NewScheduledMessageService(scheduledRepo, convoRepo, eventService)
 
-	// WebSocket Hub
-	hub := ws_delivery.NewHub(messageService, convoService, gameService, eventService) // Pass eventService to Hub
+	// WebSocket Hub, fanned out across replicas through Redis pub/sub
+	eventBus := redis.NewRedisEventBus(redisClient)
+	defer eventBus.Close()
+	hub := ws_delivery.NewHub(messageService, convoService, gameService, eventService, presenceService, scheduledService, eventBus) // Pass eventService to Hub
 	go hub.Run()
 
 	// HTTP Handlers
-	userHandler := http_delivery.NewUserHandler(userService, tokenService, cfg.JWTSecret, cfg.UploadDir)
+	userHandler := http_delivery.NewUserHandler(userService, tokenService, cfg.JWTSecret)
 	convoHandler := http_delivery.NewConversationHandler(convoService, messageService)
+	messageHandler := http_delivery.NewMessageHandler(messageService)
+	scheduledHandler := http_delivery.NewScheduledMessageHandler(scheduledService)
+	attachmentHandler := http_delivery.NewAttachmentHandler(attachmentService, cfg.MaxAttachmentSize)
 	friendshipHandler := http_delivery.NewFriendshipHandler(friendshipService)
+	presenceHandler := http_delivery.NewPresenceHandler(presenceService)
 	groupHandler := http_delivery.NewGroupHandler(groupService, convoService)
 	gameHandler := http_delivery.NewGameHandler(gameService, hub)
 	longPollingHandler := http_delivery.NewLongPollingHandler(eventService, userService) // Use eventService
+	sseHandler := http_delivery.NewSSEHandler(eventService)
 	wsHandler := ws_delivery.NewWSHandler(hub, tokenService)
 
-	// Create upload directory if it doesn't exist
-	if _, err := os.Stat(cfg.UploadDir); os.IsNotExist(err) {
-		os.Mkdir(cfg.UploadDir, 0755)
-	}
-
 	// Router
 	r := chi.NewRouter()
 	r.Use(middleware.Logger, middleware.Recoverer)
@@ -93,10 +111,11 @@ func main() {
 		MaxAge:           300,
 	}))
 
-	// Serve static files (profile pictures)
-	workDir, _ := os.Getwd()
-	filesDir := http.Dir(filepath.Join(workDir, cfg.UploadDir))
-	http_delivery.FileServer(r, "/uploads", filesDir)
+	// Profile pictures redirect to signed storage URLs; local storage serves those itself
+	r.Get("/uploads/*", userHandler.ServeProfilePicture)
+	if localStorage != nil {
+		r.Handle("/files/*", http.StripPrefix("/files", localStorage))
+	}
 
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("Real-Time Chat API"))
@@ -117,18 +136,46 @@ func main() {
 			r.Use(http_delivery.AuthMiddleware(cfg.JWTSecret, userService))
 			r.Get("/me", userHandler.GetMe)
 			r.Post("/me/avatar", userHandler.UploadProfilePicture) // Profile picture upload
+			r.Put("/me/status", userHandler.UpdateStatus)          // Custom status and do-not-disturb
+			// {"hidden": true} hides presence and last-seen time from friends
+			r.Put("/me/presence", presenceHandler.UpdatePresenceVisibility)
 
 			// Conversation & Message Routes
 			r.Get("/conversations", convoHandler.GetUserConversations)
 			r.Get("/conversations/{conversationID}/messages", convoHandler.GetMessages)
+			r.Get("/conversations/{conversationID}/pins", convoHandler.GetPinnedMessages)
 			r.Post("/conversations/{conversationID}/read", convoHandler.MarkAsRead)
+			r.Put("/conversations/{conversationID}/message-ttl", convoHandler.SetMessageTTL)     // Disappearing messages
 			r.Delete("/conversations/{conversationID}", convoHandler.DeleteOneToOneConversation) // Delete 1-1 chat
+			r.Post("/conversations/{conversationID}/scheduled", scheduledHandler.ScheduleMessage)
+			r.Get("/scheduled-messages", scheduledHandler.GetScheduledMessages)
+			r.Put("/scheduled-messages/{scheduledID}", scheduledHandler.UpdateScheduledMessage)
+			r.Delete("/scheduled-messages/{scheduledID}", scheduledHandler.CancelScheduledMessage)
+			r.Get("/messages/search", messageHandler.SearchMessages)
+			r.Post("/messages/forward", messageHandler.ForwardMessages)
+			r.Put("/messages/{messageID}", messageHandler.EditMessage)
+			r.Get("/messages/{messageID}/revisions", messageHandler.GetRevisions)
+			r.Delete("/messages/{messageID}", messageHandler.DeleteMessage) // ?scope=me|everyone
+			r.Get("/messages/{messageID}/thread", messageHandler.GetThread)
+			r.Get("/messages/{messageID}/context", messageHandler.GetMessageContext) // Messages around this one
+			r.Get("/messages/{messageID}/receipts", messageHandler.GetReceipts)
+			r.Post("/messages/{messageID}/pin", messageHandler.PinMessage)
+			r.Delete("/messages/{messageID}/pin", messageHandler.UnpinMessage)
+			r.Put("/messages/{messageID}/bookmark", messageHandler.BookmarkMessage) // {"note": "..."} optional
+			r.Delete("/messages/{messageID}/bookmark", messageHandler.RemoveBookmark)
+			r.Get("/bookmarks", messageHandler.GetBookmarks) // ?cursor=&limit=
+			r.Post("/messages/{messageID}/reactions", messageHandler.AddReaction)
+			r.Delete("/messages/{messageID}/reactions", messageHandler.RemoveReaction) // ?emoji=
+			r.Post("/conversations/{conversationID}/attachments", attachmentHandler.UploadAttachment)
+			r.Get("/attachments/{attachmentID}", attachmentHandler.DownloadAttachment)
+			r.Get("/attachments/{attachmentID}/url", attachmentHandler.GetDownloadURL)
 
 			// Friendship Routes
 			r.Post("/friends/requests", friendshipHandler.SendRequest)
 			r.Get("/friends/requests", friendshipHandler.GetRequests)
 			r.Put("/friends/requests/{requestID}", friendshipHandler.RespondToRequest)
 			r.Get("/friends", friendshipHandler.GetFriends)
+			r.Get("/friends/presence", presenceHandler.GetFriendsPresence)
 
 			// Group Routes
 			r.Post("/groups", groupHandler.CreateGroup)
@@ -143,6 +190,9 @@ func main() {
 			r.Post("/games/{gameID}/respond", gameHandler.RespondToGameInvite)
 			r.Get("/games/{gameID}", gameHandler.GetGameState)
 			r.Post("/games/{gameID}/move", gameHandler.MakeMove)
+
+			// Server-Sent Events stream, for clients whose proxies break WebSockets
+			r.Get("/events/stream", sseHandler.HandleEventStream)
 		})
 	})
 
@@ -176,3 +226,36 @@ func main() {
 	}
 	log.Println("Server exited properly")
 }
+
+// newBlobStorage builds the configured storage backend. For local storage it
+// also returns the store itself, which serves its signed URLs under /files.
+func newBlobStorage(cfg config.Config) (domain.BlobStorage, *storage.LocalBlobStorage) {
+	switch cfg.StorageBackend {
+	case "s3":
+		s3Storage, err := storage.NewS3BlobStorage(storage.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+		})
+		if err != nil {
+			log.Fatalf("Unable to configure S3 storage: %v\n", err)
+		}
+		return s3Storage, nil
+	case "local":
+		secret := cfg.StorageSigningSecret
+		if secret == "" {
+			secret = cfg.JWTSecret
+		}
+		localStorage, err := storage.NewLocalBlobStorage(cfg.UploadDir, "/files", secret)
+		if err != nil {
+			log.Fatalf("Unable to create upload directory: %v\n", err)
+		}
+		return localStorage, localStorage
+	default:
+		log.Fatalf("Unknown STORAGE_BACKEND %q, expected local or s3", cfg.StorageBackend)
+		return nil, nil
+	}
+}
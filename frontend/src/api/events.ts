@@ -2,10 +2,10 @@ import { api } from './client';
 import { type Event } from '../types/event';
 
 // This is the long-polling fallback endpoint
-export const fetchEvents = async (sinceEventId?: string, limit: number = 50): Promise<Event[]> => {
+export const fetchEvents = async (sinceSeq?: number, limit: number = 50): Promise<Event[]> => {
     const params = new URLSearchParams();
-    if (sinceEventId) {
-        params.append('since', sinceEventId);
+    if (sinceSeq) {
+        params.append('since', String(sinceSeq));
     }
     params.append('limit', String(limit));
     // Set a client-side timeout slightly less than the server's long polling timeout
@@ -1,6 +1,6 @@
 import { api } from './client';
 import type { FriendshipRequest } from '../types/friendship';
-import type { User } from '../types/user';
+import type { Presence, User } from '../types/user';
 
 export const sendFriendRequest = async (username: string): Promise<void> => {
   await api.post('/friends/requests', { username });
@@ -18,4 +18,9 @@ export const respondToFriendRequest = async (requestId: string, status: 'accepte
 export const getFriends = async (): Promise<User[]> => {
   const response = await api.get('/friends');
   return response.data;
+};
+
+export const getFriendsPresence = async (): Promise<Presence[]> => {
+  const response = await api.get('/friends/presence');
+  return response.data || [];
 };
\ No newline at end of file
@@ -1,4 +1,5 @@
 import { api } from './client';
+import type { UserStatus } from '../types/user';
 
 export const uploadProfilePicture = async (formData: FormData): Promise<string> => {
   const response = await api.post('/me/avatar', formData, {
@@ -7,4 +8,9 @@ export const uploadProfilePicture = async (formData: FormData): Promise<string>
     },
   });
   return response.data.profile_picture_url;
+};
+
+export const updateStatus = async (status: Omit<UserStatus, 'dnd_active'>): Promise<UserStatus> => {
+  const response = await api.put('/me/status', status);
+  return response.data;
 };
\ No newline at end of file
@@ -7,7 +7,7 @@ const LONG_POLLING_INTERVAL_MS = 5000; // 5 seconds
 
 export const useLongPolling = (accessToken: string | null, isWsConnected: boolean, onEvent: (event: Event) => void) => {
   const intervalRef = useRef<ReturnType<typeof setTimeout> | null>(null);
-  const lastEventIdRef = useRef<string | undefined>(undefined); // Now tracks event ID
+  const lastSeqRef = useRef<number | undefined>(undefined); // Tracks the seq of the last event seen
 
   const poll = async () => {
     if (!accessToken || isWsConnected) {
@@ -20,11 +20,11 @@ export const useLongPolling = (accessToken: string | null, isWsConnected: boolea
     }
 
     try {
-      const events = await fetchEvents(lastEventIdRef.current); // Pass lastEventId
+      const events = await fetchEvents(lastSeqRef.current); // Pass last seen seq
       if (events && events.length > 0) {
         events.forEach(event => onEvent(event));
-        // Update lastEventId to the ID of the latest event
-        lastEventIdRef.current = events[events.length - 1].id; 
+        // Update the cursor to the seq of the latest event
+        lastSeqRef.current = events[events.length - 1].seq;
       }
     } catch (error: any) {
       if (error.code === 'ECONNABORTED' || error.response?.status === 408) {
@@ -49,7 +49,7 @@ export const useLongPolling = (accessToken: string | null, isWsConnected: boolea
       // Start polling only if authenticated and WS is NOT connected
       if (!intervalRef.current) {
         console.log("Starting long polling...");
-        lastEventIdRef.current = undefined; // Reset lastEventId when starting polling
+        lastSeqRef.current = undefined; // Reset the cursor when starting polling
         poll();
       }
     } else {
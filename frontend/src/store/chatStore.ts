@@ -11,8 +11,11 @@ interface ChatState {
   messages: Record<string, Message[]>;
   hasMore: Record<string, boolean>; // Indicates if there are more messages to fetch for a conversation
   activeConversationId: string | null;
+  typingUsers: Record<string, Record<string, number>>; // conversationId -> userId -> expiry (ms since epoch)
+  setTyping: (conversationId: string, userId: string, expiresInMs: number) => void;
   fetchConversations: () => Promise<void>;
   addMessage: (message: Message, fromSocket?: boolean) => void;
+  removeMessages: (conversationId: string, messageIds: string[]) => Promise<void>;
   setActiveConversationId: (id: string | null) => Promise<void>;
   loadMessagesFromDB: (conversationId: string) => Promise<void>;
   fetchOlderMessages: (conversationId: string) => Promise<void>;
@@ -29,6 +32,26 @@ export const useChatStore = create<ChatState>((set, get) => ({
   messages: {},
   hasMore: {},
   activeConversationId: null,
+  typingUsers: {},
+
+  setTyping: (conversationId, userId, expiresInMs) => {
+    set(state => {
+      const users = { ...(state.typingUsers[conversationId] || {}) };
+      if (expiresInMs > 0) {
+        users[userId] = Date.now() + expiresInMs;
+        // Drop the indicator ourselves if the stop never arrives
+        setTimeout(() => {
+          const expiry = get().typingUsers[conversationId]?.[userId];
+          if (expiry !== undefined && expiry <= Date.now()) {
+            get().setTyping(conversationId, userId, 0);
+          }
+        }, expiresInMs);
+      } else {
+        delete users[userId];
+      }
+      return { typingUsers: { ...state.typingUsers, [conversationId]: users } };
+    });
+  },
 
   fetchConversations: async () => {
     try {
@@ -88,6 +111,23 @@ export const useChatStore = create<ChatState>((set, get) => ({
     });
   },
 
+  // Drops messages the server no longer has, e.g. expired disappearing messages
+  removeMessages: async (conversationId, messageIds) => {
+    await db.messages.bulkDelete(messageIds);
+    const removed = new Set(messageIds);
+    set(state => {
+      const remaining = (state.messages[conversationId] || []).filter(m => !removed.has(m.id));
+      return {
+        messages: { ...state.messages, [conversationId]: remaining },
+        conversations: state.conversations.map(c =>
+          c.id === conversationId && c.lastMessage && removed.has(c.lastMessage.id)
+            ? { ...c, lastMessage: remaining[remaining.length - 1] }
+            : c
+        ),
+      };
+    });
+  },
+
   setActiveConversationId: async (id) => {
     if (!id) {
       set({ activeConversationId: null });
@@ -197,5 +237,5 @@ export const useChatStore = create<ChatState>((set, get) => ({
     }
   },
   
-  clearChatData: () => set({ conversations: [], messages: {}, activeConversationId: null, hasMore: {} })
+  clearChatData: () => set({ conversations: [], messages: {}, activeConversationId: null, hasMore: {}, typingUsers: {} })
 }));
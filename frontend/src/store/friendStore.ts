@@ -1,13 +1,17 @@
 import { create } from 'zustand';
-import { getFriends as apiGetFriends, getFriendRequests, respondToFriendRequest, sendFriendRequest } from '../api/friends';
-import { type User } from '../types/user';
+import { getFriends as apiGetFriends, getFriendRequests, getFriendsPresence, respondToFriendRequest, sendFriendRequest } from '../api/friends';
+import { type Presence, type User, type UserStatus } from '../types/user';
 import { type FriendshipRequest } from '../types/friendship';
 import { toast } from '../hooks/use-toast';
 
 interface FriendState {
   friends: User[];
   requests: FriendshipRequest[];
+  presence: Record<string, Presence>; // Keyed by friend's user ID
   fetchFriends: () => Promise<void>;
+  fetchPresence: () => Promise<void>;
+  setPresence: (presence: Presence) => void;
+  setFriendStatus: (userId: string, status: UserStatus) => void;
   fetchRequests: () => Promise<void>;
   sendRequest: (username: string) => Promise<void>;
   respondToRequest: (requestId: string, status: 'accepted' | 'declined') => Promise<void>;
@@ -16,6 +20,7 @@ interface FriendState {
 export const useFriendStore = create<FriendState>((set, get) => ({
   friends: [],
   requests: [],
+  presence: {},
 
   fetchFriends: async () => {
     try {
@@ -27,6 +32,23 @@ export const useFriendStore = create<FriendState>((set, get) => ({
     }
   },
 
+  fetchPresence: async () => {
+    try {
+      const presences = await getFriendsPresence();
+      set({ presence: Object.fromEntries(presences.map(p => [p.user_id, p])) });
+    } catch (error) {
+      console.error("Failed to fetch presence:", error);
+    }
+  },
+
+  setPresence: (presence) => {
+    set(state => ({ presence: { ...state.presence, [presence.user_id]: presence } }));
+  },
+
+  setFriendStatus: (userId, status) => {
+    set(state => ({ friends: state.friends.map(f => (f.id === userId ? { ...f, status } : f)) }));
+  },
+
   fetchRequests: async () => {
     try {
       const requests = await getFriendRequests();
@@ -18,6 +18,7 @@ interface SocketState {
   disconnect: () => void;
   sendMessage: (type: string, payload: any) => void;
   reconnectAttempt: number;
+  lastSeq: number | null; // Sent on reconnect so the server replays missed events
 }
 
 const WEBSOCKET_URL = import.meta.env.VITE_WEBSOCKET_URL || 'ws://localhost:8080/ws';
@@ -25,11 +26,20 @@ const RECONNECT_MAX_ATTEMPTS = 5;
 const RECONNECT_INTERVAL_MS = 3000; // 3 seconds
 const WS_TIMEOUT_FALLBACK_MS = 60000; // 60 seconds
 
+// Report the tab as away while it is hidden, so friends see the user idle
+const reportVisibility = () => {
+  const ws = useSocketStore.getState().socket;
+  if (ws && ws.readyState === WebSocket.OPEN) {
+    ws.send(JSON.stringify({ type: 'heartbeat', payload: { status: document.hidden ? 'away' : 'online' } }));
+  }
+};
+
 export const useSocketStore = create<SocketState>((set, get) => ({
   socket: null,
   isConnected: false,
   isConnecting: false,
   reconnectAttempt: 0,
+  lastSeq: null,
   
   connect: (token) => {
     if (get().isConnected || get().isConnecting) {
@@ -38,7 +48,9 @@ export const useSocketStore = create<SocketState>((set, get) => ({
     
     set({ isConnecting: true });
     
-    const ws = new WebSocket(`${WEBSOCKET_URL}?token=${token}`);
+    const lastSeq = get().lastSeq;
+    const resumeParam = lastSeq !== null ? `&last_seq=${lastSeq}` : '';
+    const ws = new WebSocket(`${WEBSOCKET_URL}?token=${token}${resumeParam}`);
     let wsFallbackTimeout: ReturnType<typeof setTimeout>;
 
     ws.onopen = () => {
@@ -46,16 +58,53 @@ export const useSocketStore = create<SocketState>((set, get) => ({
       set({ isConnected: true, isConnecting: false, socket: ws, reconnectAttempt: 0 });
       toast({ title: 'Connected', description: 'Real-time communication established.' });
       clearTimeout(wsFallbackTimeout); // Clear any pending fallback
+      document.addEventListener('visibilitychange', reportVisibility);
+      if (document.hidden) {
+        reportVisibility();
+      }
+      useFriendStore.getState().fetchPresence();
     };
 
     ws.onmessage = (event) => {
       try {
         const parsedEvent: Event = JSON.parse(event.data); // Events now come wrapped as a general Event type
         // console.log('WebSocket event received:', parsedEvent);
+        if (parsedEvent.seq !== undefined) {
+          set({ lastSeq: parsedEvent.seq }); // Ephemeral events (typing) carry no seq
+        }
 
         switch (parsedEvent.event_type) { // Use event_type from the Event object
             case 'new_message':
                 useChatStore.getState().addMessage(parsedEvent.payload, true);
+                // Acknowledge receipt so the sender sees it as delivered
+                ws.send(JSON.stringify({ type: 'message_delivered', payload: { message_id: parsedEvent.payload.id } }));
+                break;
+            case 'presence_updated':
+                useFriendStore.getState().setPresence(parsedEvent.payload);
+                break;
+            case 'status_updated':
+                useFriendStore.getState().setFriendStatus(parsedEvent.payload.user_id, parsedEvent.payload.status);
+                break;
+            case 'typing_started':
+                useChatStore.getState().setTyping(parsedEvent.payload.conversation_id, parsedEvent.payload.user_id, parsedEvent.payload.expires_in_ms);
+                break;
+            case 'typing_stopped':
+                useChatStore.getState().setTyping(parsedEvent.payload.conversation_id, parsedEvent.payload.user_id, 0);
+                break;
+            case 'scheduled_message_sent':
+                break; // The message itself arrives as new_message
+            case 'scheduled_message_failed':
+                toast({
+                    title: "Scheduled Message Not Sent",
+                    description: parsedEvent.payload.reason,
+                    variant: "destructive",
+                    duration: 5000,
+                });
+                break;
+            case 'message_ttl_updated':
+                break; // Participants also get a system message announcing the change
+            case 'messages_expired':
+                useChatStore.getState().removeMessages(parsedEvent.payload.conversation_id, parsedEvent.payload.message_ids);
                 break;
             case 'friend_request':
                 toast({ 
@@ -164,7 +213,7 @@ export const useSocketStore = create<SocketState>((set, get) => ({
 
   disconnect: () => {
     get().socket?.close();
-    set({ socket: null, isConnected: false, isConnecting: false, reconnectAttempt: 0 });
+    set({ socket: null, isConnected: false, isConnecting: false, reconnectAttempt: 0, lastSeq: null });
   },
 
   sendMessage: (type, payload) => {
@@ -8,11 +8,20 @@ export type EventType =
     "group_created" |
     "group_joined" |
     "group_left" |
-    "conversation_deleted";
+    "conversation_deleted" |
+    "presence_updated" |
+    "status_updated" |
+    "typing_started" |
+    "typing_stopped" |
+    "scheduled_message_sent" |
+    "scheduled_message_failed" |
+    "message_ttl_updated" |
+    "messages_expired";
 
 export interface Event {
     id: string;
     user_id: string; // The user this event is primarily relevant to
+    seq?: number; // Strictly increasing per user; used as the resume cursor. Absent on ephemeral events
     event_type: EventType;
     payload: any; // The actual data of the event, type depends on event_type
     server_timestamp: string; // ISO 8601 date string
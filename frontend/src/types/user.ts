@@ -2,4 +2,28 @@ export interface User {
   id: string;
   username: string;
   profilePictureUrl: string;
+  status?: UserStatus;
+}
+
+export interface DNDSchedule {
+  start: string; // "HH:MM"
+  end: string; // "HH:MM"; earlier than start wraps past midnight
+  time_zone: string; // IANA name
+}
+
+export interface UserStatus {
+  text?: string;
+  emoji?: string;
+  expires_at?: string;
+  do_not_disturb: boolean;
+  dnd_schedule?: DNDSchedule;
+  dnd_active: boolean; // Set by the server: switch on or inside the schedule
+}
+
+export type PresenceStatus = 'online' | 'away' | 'offline';
+
+export interface Presence {
+  user_id: string;
+  status: PresenceStatus;
+  last_seen_at?: string; // Absent while online, or if the user hides their presence
 }